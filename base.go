@@ -2,18 +2,21 @@ package esutils
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"io"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/olivere/elastic/v7"
-	"github.com/sirupsen/logrus"
 	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:generate go-doudou name --file $GOFILE
@@ -28,6 +31,8 @@ const (
 	MUST
 	// MUSTNOT represents must_not query
 	MUSTNOT
+	// FILTER represents filter query, it is like MUST but does not affect score and can be cached by es
+	FILTER
 )
 
 const (
@@ -43,6 +48,28 @@ const (
 	WILDCARD
 	// EXISTS https://www.elastic.co/guide/en/elasticsearch/reference/6.8/query-dsl-prefix-query.html
 	EXISTS
+	// NESTED wraps Children in nested query on QueryCond.Path
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-nested-query.html
+	NESTED
+	// GEODISTANCE https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-geo-distance-query.html
+	GEODISTANCE
+	// GEOBOUNDINGBOX https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-geo-bounding-box-query.html
+	GEOBOUNDINGBOX
+	// GEOPOLYGON https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-geo-polygon-query.html
+	GEOPOLYGON
+	// FUZZY https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-fuzzy-query.html
+	FUZZY
+	// REGEXP https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-regexp-query.html
+	REGEXP
+	// IDS matches docs by ids given in Pair with key _id
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-ids-query.html
+	IDS
+	// TERM https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-term-query.html
+	TERM
+	// BOOLPREFIX matches the last term as prefix and other terms as whole on a search_as_you_type field and its shingle
+	// subfields for type-ahead
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-as-you-type.html
+	BOOLPREFIX
 )
 
 type esFieldType string
@@ -66,6 +93,14 @@ const (
 	FLOAT esFieldType = "float"
 	// BOOL represents bool field type
 	BOOL esFieldType = "boolean"
+	// GEOPOINT represents geo_point field type
+	GEOPOINT esFieldType = "geo_point"
+	// GEOSHAPE represents geo_shape field type
+	GEOSHAPE esFieldType = "geo_shape"
+	// COMPLETION represents completion field type for completion suggester
+	COMPLETION esFieldType = "completion"
+	// SEARCHASYOUTYPE represents search_as_you_type field type
+	SEARCHASYOUTYPE esFieldType = "search_as_you_type"
 )
 
 // Es defines properties for connecting to an es instance
@@ -76,7 +111,50 @@ type Es struct {
 	username string          `json:"username"`
 	password string          `json:"password"`
 	urls     []string        `json:"urls"`
-	logger   *logrus.Logger  `json:"logger"`
+	logger   Logger          `json:"logger"`
+	// scrollSlices is the default number of slices scrolled concurrently when fetching all docs
+	scrollSlices int
+	// scrollConcurrency is the default number of goroutines decoding scrolled hits
+	scrollConcurrency int
+	// scrollKeepAlive is the default keep-alive of scroll contexts
+	scrollKeepAlive string
+	// fieldTypes caches field types flattened from GetMapping, guarded by mappingMu. mappingGen counts invalidations
+	// so that a mapping fetched before an invalidation isn't cached
+	fieldTypes map[string]string
+	mappingGen uint64
+	mappingMu  sync.Mutex
+	// caCert, certFile, keyFile, insecureSkipVerify and tlsConfig build tls config of the default client
+	caCert             string
+	certFile           string
+	keyFile            string
+	insecureSkipVerify bool
+	tlsConfig          *tls.Config
+	// apiKey and bearerToken are sent in Authorization header instead of basic auth
+	apiKey      string
+	bearerToken string
+	// cloudID resolves urls of an Elastic Cloud deployment
+	cloudID    string
+	headers    http.Header
+	httpClient *http.Client
+	transport  http.RoundTripper
+	// sniff and healthcheck make the default client discover nodes and skip dead ones
+	sniff               bool
+	sniffInterval       time.Duration
+	healthcheck         bool
+	healthcheckInterval time.Duration
+	retryPolicy         *RetryPolicy
+	requestTimeout      time.Duration
+	// traceLog logs request and response bodies at trace level without headers
+	traceLog       bool
+	tracerProvider trace.TracerProvider
+	metrics        Metrics
+	// slowQueryThreshold enables slow query log if greater than 0
+	slowQueryThreshold time.Duration
+	// hooks are called around operations
+	hooks []Hook
+	// customClient is true if the client is set by WithClient, headerWarning logs once that it doesn't send Call.Header
+	customClient  bool
+	headerWarning sync.Once
 }
 
 func (e *Es) GetIndex() string {
@@ -98,68 +176,136 @@ func (e *Es) SetType(estype string) {
 }
 
 func (e *Es) newDefaultClient() {
-	client, err := elastic.NewSimpleClient(
-		elastic.SetErrorLog(e.logger),
+	headers, err := e.newHeaders()
+	if err != nil {
+		panic(fmt.Errorf("newHeaders() error: %+v\n", err))
+	}
+	options := []elastic.ClientOptionFunc{
+		elastic.SetErrorLog(printfLogger(e.logger.Errorf)),
+		elastic.SetInfoLog(printfLogger(e.logger.Infof)),
 		elastic.SetURL(e.urls...),
 		elastic.SetBasicAuth(e.username, e.password),
 		elastic.SetGzip(true),
-	)
+		elastic.SetHeaders(headers),
+	}
+	httpClient, err := e.newHTTPClient()
 	if err != nil {
-		panic(fmt.Errorf("NewSimpleClient() error: %+v\n", err))
+		panic(fmt.Errorf("newHTTPClient() error: %+v\n", err))
+	}
+	if httpClient != nil {
+		options = append(options, elastic.SetHttpClient(httpClient))
+	}
+	if e.retryPolicy != nil {
+		options = append(options, elastic.SetRetrier(retrier{policy: *e.retryPolicy}), elastic.SetRetryStatusCodes(e.retryPolicy.statusCodes()...))
+	}
+	if !e.sniff && !e.healthcheck {
+		client, err := elastic.NewSimpleClient(options...)
+		if err != nil {
+			panic(fmt.Errorf("NewSimpleClient() error: %+v\n", err))
+		}
+		e.client = client
+		return
+	}
+	options = append(options, elastic.SetSniff(e.sniff), elastic.SetHealthcheck(e.healthcheck))
+	if e.sniffInterval > 0 {
+		options = append(options, elastic.SetSnifferInterval(e.sniffInterval))
+	}
+	if e.healthcheckInterval > 0 {
+		options = append(options, elastic.SetHealthcheckInterval(e.healthcheckInterval))
+	}
+	client, err := elastic.NewClient(options...)
+	if err != nil {
+		panic(fmt.Errorf("NewClient() error: %+v\n", err))
 	}
 	e.client = client
 }
 
-func (e *Es) fetchAll(fsc *elastic.FetchSourceContext, boolQuery *elastic.BoolQuery, scrollSize int, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
-	var (
-		rets []interface{}
-		err  error
-	)
+// scrollSettings resolves scroll slices, consumer concurrency and keep-alive from paging, falling back to es defaults
+func (e *Es) scrollSettings(paging *Paging) (slices int, concurrency int, keepAlive string) {
+	slices, concurrency, keepAlive = e.scrollSlices, e.scrollConcurrency, e.scrollKeepAlive
+	if paging != nil {
+		if paging.ScrollSlices > 0 {
+			slices = paging.ScrollSlices
+		}
+		if paging.ScrollConcurrency > 0 {
+			concurrency = paging.ScrollConcurrency
+		}
+		if stringutils.IsNotEmpty(paging.ScrollKeepAlive) {
+			keepAlive = paging.ScrollKeepAlive
+		}
+	}
+	if slices <= 0 {
+		slices = 1
+	}
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	if stringutils.IsEmpty(keepAlive) {
+		keepAlive = "1m"
+	}
+	return
+}
+
+// scrollSlice scrolls the slice specified by id out of max slices and sends hits to the channel,
+// the whole index is scrolled if max is not greater than 1
+func (e *Es) scrollSlice(ctx context.Context, fsc *elastic.FetchSourceContext, esQuery elastic.Query, scrollSize int, keepAlive string, id, max int, hits chan<- *elastic.SearchHit) error {
+	scroll := e.client.Scroll().Index(e.esIndex).Type(e.esType).Query(esQuery).FetchSourceContext(fsc).Size(scrollSize).KeepAlive(keepAlive)
+	if max > 1 {
+		scroll = scroll.Slice(elastic.NewSliceQuery().Id(id).Max(max))
+	}
+	defer e.scrollOpened()()
+	defer scroll.Clear(detached(ctx))
+	for {
+		results, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "call Scroll() error")
+		}
+		for _, hit := range results.Hits.Hits {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case hits <- hit:
+			}
+		}
+	}
+}
+
+func (e *Es) fetchAll(ctx context.Context, fsc *elastic.FetchSourceContext, paging *Paging, esQuery elastic.Query, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
+	var rets []interface{}
+	scrollSize := paging.ScrollSize
+	if scrollSize <= 0 {
+		scrollSize = 1000
+	}
+	slices, concurrency, keepAlive := e.scrollSettings(paging)
 	hits := make(chan *elastic.SearchHit)
-	g, ctx := errgroup.WithContext(context.Background())
+	g, ctx := errgroup.WithContext(ctx)
 	g.Go(func() error {
 		defer close(hits)
-		scroll := e.client.Scroll().Index(e.esIndex).Type(e.esType).Query(boolQuery).FetchSourceContext(fsc).Size(scrollSize).KeepAlive("1m")
-		for {
-			results, err := scroll.Do(ctx)
-			if err == io.EOF {
-				return nil
-			}
-			if err != nil {
-				return errors.Wrap(err, "call Scroll() error")
-			}
-			for _, hit := range results.Hits.Hits {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				default:
-					hits <- hit
-				}
-			}
+		sg, sctx := errgroup.WithContext(ctx)
+		for i := 0; i < slices; i++ {
+			id := i
+			sg.Go(func() error {
+				return e.scrollSlice(sctx, fsc, esQuery, scrollSize, keepAlive, id, slices, hits)
+			})
 		}
-		return nil
+		return sg.Wait()
 	})
 
 	c := make(chan interface{})
-	for i := 0; i < 10; i++ {
+	for i := 0; i < concurrency; i++ {
 		g.Go(func() error {
 			for hit := range hits {
+				ret, err := hitToDoc(hit, paging, callback)
+				if err != nil {
+					return err
+				}
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
-				default:
-					var ret interface{}
-					if callback == nil {
-						var p map[string]interface{}
-						json.Unmarshal(hit.Source, &p)
-						p["_id"] = hit.Id
-						ret = p
-					} else {
-						if ret, err = callback(hit.Source); err != nil {
-							return errors.Wrap(err, "call callback() error")
-						}
-					}
-					c <- ret
+				case c <- ret:
 				}
 			}
 			return nil
@@ -181,39 +327,94 @@ func (e *Es) fetchAll(fsc *elastic.FetchSourceContext, boolQuery *elastic.BoolQu
 	return rets, nil
 }
 
-func (e *Es) doPaging(ctx context.Context, fsc *elastic.FetchSourceContext, paging *Paging, boolQuery *elastic.BoolQuery, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
+func (e *Es) doPaging(ctx context.Context, fsc *elastic.FetchSourceContext, paging *Paging, esQuery elastic.Query, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
 	var (
 		rets         []interface{}
 		searchResult *elastic.SearchResult
 		err          error
 	)
-	ss := e.client.Search().Index(e.esIndex).Type(e.esType).Query(boolQuery).FetchSourceContext(fsc)
+	ss := e.newSearchService(paging).Query(esQuery).FetchSourceContext(fsc)
 	if paging.Sortby != nil && len(paging.Sortby) > 0 {
 		for _, v := range paging.Sortby {
-			ss = ss.Sort(v.Field, v.Ascending)
+			ss = ss.SortBy(v.sorter())
 		}
 	}
-	if searchResult, err = ss.From(paging.Skip).Size(paging.Limit).Do(ctx); err != nil {
+	if paging.Collapse != nil {
+		ss = ss.Collapse(paging.Collapse.builder())
+	}
+	if len(paging.SearchAfter) > 0 {
+		ss = ss.SearchAfter(paging.SearchAfter...)
+	} else {
+		ss = ss.From(paging.Skip)
+	}
+	if searchResult, err = ss.Size(paging.Limit).Do(ctx); err != nil {
 		return nil, errors.Wrap(err, "call Search() error")
 	}
+	recordSearch(ctx, searchResult)
 	for _, hit := range searchResult.Hits.Hits {
 		var ret interface{}
-		if callback == nil {
-			var p map[string]interface{}
-			json.Unmarshal(hit.Source, &p)
-			p["_id"] = hit.Id
-			ret = p
-		} else {
-			if ret, err = callback(hit.Source); err != nil {
-				return nil, errors.Wrap(err, "call callback() error")
-			}
+		if ret, err = hitToDoc(hit, paging, callback); err != nil {
+			return nil, err
 		}
-
 		rets = append(rets, ret)
 	}
 	return rets, nil
 }
 
+// innerHitsToDocs converts inner hits to sources keyed by inner hits name
+func innerHitsToDocs(innerHits map[string]*elastic.SearchHitInnerHits) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for name, ih := range innerHits {
+		docs := make([]interface{}, 0)
+		if ih != nil && ih.Hits != nil {
+			for _, hit := range ih.Hits.Hits {
+				var p map[string]interface{}
+				json.Unmarshal(hit.Source, &p)
+				docs = append(docs, p)
+			}
+		}
+		ret[name] = docs
+	}
+	return ret
+}
+
+// newSearchService searches the point in time specified by paging.PitID if any, otherwise searches es.esIndex
+func (e *Es) newSearchService(paging *Paging) *elastic.SearchService {
+	if paging != nil && stringutils.IsNotEmpty(paging.PitID) {
+		keepAlive := paging.PitKeepAlive
+		if stringutils.IsEmpty(keepAlive) {
+			keepAlive = "1m"
+		}
+		return e.client.Search().PointInTime(elastic.NewPointInTimeWithKeepAlive(paging.PitID, keepAlive))
+	}
+	return e.client.Search().Index(e.esIndex).Type(e.esType)
+}
+
+func hitToDoc(hit *elastic.SearchHit, paging *Paging, callback func(message json.RawMessage) (interface{}, error)) (interface{}, error) {
+	if callback == nil {
+		var p map[string]interface{}
+		json.Unmarshal(hit.Source, &p)
+		if p == nil {
+			p = make(map[string]interface{})
+		}
+		p["_id"] = hit.Id
+		if len(hit.InnerHits) > 0 {
+			p["_inner_hits"] = innerHitsToDocs(hit.InnerHits)
+		}
+		if paging != nil {
+			if i := geoSortIndex(paging.Sortby); i >= 0 && i < len(hit.Sort) {
+				p["_distance"] = hit.Sort[i]
+			}
+		}
+		return p, nil
+	}
+	ret, err := callback(hit.Source)
+	if err != nil {
+		return nil, errors.Wrap(err, "call callback() error")
+	}
+	return ret, nil
+}
+
 // EsOption represents functions for changing Es properties
 type EsOption func(*Es)
 
@@ -238,13 +439,21 @@ func WithPassword(password string) EsOption {
 	}
 }
 
-// WithLogger sets logger
-func WithLogger(logger *logrus.Logger) EsOption {
+// WithLogger sets logger, default logger writes info and error messages to stderr
+func WithLogger(logger Logger) EsOption {
 	return func(es *Es) {
 		es.logger = logger
 	}
 }
 
+// WithTraceLog logs request and response bodies at trace level of logger, or debug level if logger has no trace level.
+// Headers are not logged so credentials stay out of logs, bodies are only logged by the default client
+func WithTraceLog(enabled bool) EsOption {
+	return func(es *Es) {
+		es.traceLog = enabled
+	}
+}
+
 // WithUrls set urls
 func WithUrls(urls []string) EsOption {
 	return func(es *Es) {
@@ -259,6 +468,28 @@ func WithType(esType string) EsOption {
 	}
 }
 
+// WithScrollSlices sets default number of slices for sliced scroll when fetching all docs
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/paginate-search-results.html#slice-scroll
+func WithScrollSlices(slices int) EsOption {
+	return func(es *Es) {
+		es.scrollSlices = slices
+	}
+}
+
+// WithScrollConcurrency sets default number of goroutines decoding scrolled hits
+func WithScrollConcurrency(concurrency int) EsOption {
+	return func(es *Es) {
+		es.scrollConcurrency = concurrency
+	}
+}
+
+// WithScrollKeepAlive sets default keep-alive of scroll contexts, e.g. 1m
+func WithScrollKeepAlive(keepAlive string) EsOption {
+	return func(es *Es) {
+		es.scrollKeepAlive = keepAlive
+	}
+}
+
 // NewEs creates an Es instance
 func NewEs(esIndex string, opts ...EsOption) *Es {
 	es := &Es{
@@ -269,13 +500,23 @@ func NewEs(esIndex string, opts ...EsOption) *Es {
 		opt(es)
 	}
 	if es.logger == nil {
-		es.logger = newLogger(logrus.InfoLevel)
+		es.logger = newLogger(false)
+	}
+	if stringutils.IsNotEmpty(es.cloudID) && es.client == nil {
+		url, err := cloudURL(es.cloudID)
+		if err != nil {
+			panic(fmt.Errorf("cloudURL() error: %+v\n", err))
+		}
+		es.urls = []string{url}
 	}
 	if len(es.urls) == 0 && es.client == nil {
 		panic("NewEs() error: you must provide urls or elastic client")
 	}
 	if es.client == nil {
 		es.newDefaultClient()
+	} else {
+		es.customClient = true
+		es.warnClientOptions()
 	}
 	return es
 }
@@ -313,6 +554,50 @@ type Field struct {
 	Name   string      `json:"name"`
 	Type   esFieldType `json:"type"`
 	Format string      `json:"format"`
+	// Contexts are contexts of COMPLETION field for filtering suggestions
+	Contexts []CompletionContext `json:"contexts"`
+	// MaxShingleSize is max_shingle_size of SEARCHASYOUTYPE field, from 2 to 4, default is 3
+	MaxShingleSize int `json:"maxShingleSize"`
+}
+
+// CompletionContext defines a context of completion field
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-suggesters.html#context-suggester
+type CompletionContext struct {
+	Name string `json:"name"`
+	// Type is category or geo
+	Type string `json:"type"`
+	// Path is the field which context values are indexed from, optional
+	Path string `json:"path"`
+	// Precision is geohash precision of geo context, optional
+	Precision interface{} `json:"precision"`
+}
+
+// property returns mapping of the field
+func (f Field) property() map[string]interface{} {
+	property := map[string]interface{}{
+		"type": f.Type,
+	}
+	if f.Type == COMPLETION && len(f.Contexts) > 0 {
+		var contexts []interface{}
+		for _, c := range f.Contexts {
+			ctx := map[string]interface{}{
+				"name": c.Name,
+				"type": c.Type,
+			}
+			if stringutils.IsNotEmpty(c.Path) {
+				ctx["path"] = c.Path
+			}
+			if c.Precision != nil {
+				ctx["precision"] = c.Precision
+			}
+			contexts = append(contexts, ctx)
+		}
+		property["contexts"] = contexts
+	}
+	if f.Type == SEARCHASYOUTYPE && f.MaxShingleSize > 0 {
+		property["max_shingle_size"] = f.MaxShingleSize
+	}
+	return property
 }
 
 // QueryCond defines query conditions
@@ -321,42 +606,140 @@ type QueryCond struct {
 	QueryLogic queryLogic               `json:"queryLogic"`
 	QueryType  queryType                `json:"queryType"`
 	Children   []QueryCond              `json:"children"`
+	// Path is the path of nested field for NESTED query, fields of Children should be full paths like line.sku
+	Path string `json:"path"`
+	// ScoreMode is score_mode of NESTED query, one of avg, max, min, sum and none
+	ScoreMode string `json:"scoreMode"`
+	// InnerHits returns matched nested objects of NESTED query in _inner_hits of each doc
+	InnerHits *InnerHits `json:"innerHits"`
+	// Fuzziness is the maximum edit distance of FUZZY query, e.g. AUTO, 1 or 2, default is AUTO
+	Fuzziness string `json:"fuzziness"`
+	// PrefixLength is the number of beginning characters left unchanged by FUZZY query
+	PrefixLength int `json:"prefixLength"`
+	// MaxExpansions is the maximum number of variations created by FUZZY query
+	MaxExpansions int `json:"maxExpansions"`
+	// Flags enables optional operators of REGEXP query, e.g. ALL or COMPLEMENT|INTERVAL
+	Flags string `json:"flags"`
+	// CaseInsensitive makes TERM, PREFIX, WILDCARD and REGEXP queries case insensitive
+	CaseInsensitive bool `json:"caseInsensitive"`
+	// Boost multiplies the score of the condition, ignored by EXISTS and geo queries
+	Boost float64 `json:"boost"`
+	// MaxShingleSize is max_shingle_size of the SEARCHASYOUTYPE field of BOOLPREFIX query, its shingle subfields
+	// up to _<MaxShingleSize>gram are queried, default is 3
+	MaxShingleSize int `json:"maxShingleSize"`
+	// MinimumShouldMatch is the minimum_should_match of the bool query built from Children, e.g. 2 or 75%.
+	// SHOULD conditions at the root level are controlled by Paging.MinimumShouldMatch instead.
+	MinimumShouldMatch string `json:"minimumShouldMatch"`
+}
+
+// InnerHits defines inner_hits options
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/inner-hits.html
+type InnerHits struct {
+	// Name is the key in _inner_hits, defaults to the nested path
+	Name     string   `json:"name"`
+	From     int      `json:"from"`
+	Size     int      `json:"size"`
+	Sortby   []Sort   `json:"sortby"`
+	Includes []string `json:"includes"`
+	Excludes []string `json:"excludes"`
+}
+
+func (ih *InnerHits) innerHit() *elastic.InnerHit {
+	innerHit := elastic.NewInnerHit()
+	if stringutils.IsNotEmpty(ih.Name) {
+		innerHit = innerHit.Name(ih.Name)
+	}
+	if ih.From > 0 {
+		innerHit = innerHit.From(ih.From)
+	}
+	if ih.Size > 0 {
+		innerHit = innerHit.Size(ih.Size)
+	}
+	for _, v := range ih.Sortby {
+		innerHit = innerHit.SortBy(v.sorter())
+	}
+	if len(ih.Includes) > 0 || len(ih.Excludes) > 0 {
+		innerHit = innerHit.FetchSourceContext(elastic.NewFetchSourceContext(true).Include(ih.Includes...).Exclude(ih.Excludes...))
+	}
+	return innerHit
 }
 
 // Sort defines sort condition
 type Sort struct {
 	Field     string `json:"field"`
 	Ascending bool   `json:"ascending"`
+	// GeoPoint sorts by distance between geo_point Field and GeoPoint, the distance is returned in _distance of each doc
+	GeoPoint *GeoPoint `json:"geoPoint"`
+	// Unit is the distance unit of geo distance sort, e.g. km, default is m
+	Unit string `json:"unit"`
 }
 
 // Paging defines pagination query conditions
 type Paging struct {
-	StartDate  string      `json:"startDate"`
-	EndDate    string      `json:"endDate"`
-	DateField  string      `json:"dateField"`
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	// DateField is filtered by StartDate and EndDate in a filter clause, so the date range does not affect score.
+	// Either StartDate or EndDate can be empty, see DateRange for supported values. StartDate and EndDate are ignored
+	// if DateField is empty
+	DateField string `json:"dateField"`
+	// DateRanges adds more date windows, e.g. on other fields or with other formats
+	DateRanges []DateRange `json:"dateRanges"`
 	QueryConds []QueryCond `json:"queryConds"`
-	Skip       int         `json:"skip"`
-	Limit      int         `json:"limit"`
-	Sortby     []Sort      `json:"sortby"`
+	// MinimumShouldMatch is the minimum_should_match of the root bool query if QueryConds has SHOULD conditions,
+	// e.g. 2 or 75%, default is 1
+	MinimumShouldMatch string `json:"minimumShouldMatch"`
+	// Relevance customizes score of docs for Page and List
+	Relevance *Relevance `json:"relevance"`
+	// Collapse returns only the top doc of each group for Page and List, List needs Limit between 0 and 10000
+	Collapse *Collapse `json:"collapse"`
+	// Profile returns es profile output of Page in PageResult.Profile for debugging performance
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-profile.html
+	Profile bool   `json:"profile"`
+	Skip    int    `json:"skip"`
+	Limit   int    `json:"limit"`
+	Sortby  []Sort `json:"sortby"`
 	// https://www.elastic.co/guide/en/elasticsearch/reference/6.8/search-request-source-filtering.html
 	Includes   []string `json:"includes"`
 	Excludes   []string `json:"excludes"`
 	ScrollSize int      `json:"scrollSize"`
-	Zone       string   `json:"zone"`
+	// ScrollSlices overrides WithScrollSlices for this request
+	ScrollSlices int `json:"scrollSlices"`
+	// ScrollConcurrency overrides WithScrollConcurrency for this request
+	ScrollConcurrency int `json:"scrollConcurrency"`
+	// ScrollKeepAlive overrides WithScrollKeepAlive for this request
+	ScrollKeepAlive string `json:"scrollKeepAlive"`
+	Zone            string `json:"zone"`
+	// PitID searches the point in time opened by OpenPIT instead of the index
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/point-in-time-api.html
+	PitID string `json:"pitId"`
+	// PitKeepAlive extends the point in time on every request, default is 1m
+	PitKeepAlive string `json:"pitKeepAlive"`
+	// SearchAfter continues from the sort values of the last hit of the previous page, Skip is ignored if set
+	// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/paginate-search-results.html#search-after
+	SearchAfter []interface{} `json:"searchAfter"`
+	// RandomSeed makes Random and Sample reproducible, docs are shuffled in the same order for the same seed
+	RandomSeed string `json:"randomSeed"`
+	// RandomField is the field used with RandomSeed to compute random score, default is _seq_no
+	RandomField string `json:"randomField"`
 }
 
-// String prints query in json format for debug purpose
+// String prints query in json format for debug purpose, or the error if the query is invalid
 func (p Paging) String() string {
-	var zone *time.Location
+	var (
+		zone *time.Location
+		err  error
+	)
 	if stringutils.IsNotEmpty(p.Zone) {
-		var err error
 		zone, err = time.LoadLocation(p.Zone)
 		if err != nil {
-			panic(err)
+			return errors.Wrap(err, "call LoadLocation() error").Error()
 		}
 	}
-	bq := query(p.StartDate, p.EndDate, p.DateField, p.QueryConds, zone)
-	src, _ := bq.Source()
+	bq, err := pagingQuery(&p, zone)
+	if err != nil {
+		return errors.Wrap(err, "call pagingQuery() error").Error()
+	}
+	src, _ := scoreQuery(bq, p.Relevance).Source()
 	return gabs.Wrap(src).StringIndent("", "  ")
 }
 
@@ -377,6 +760,22 @@ func querynode(boolQuery *elastic.BoolQuery, qc QueryCond) {
 			wildcard(boolQuery, qc, field, value)
 		} else if qc.QueryType == EXISTS {
 			exists(boolQuery, qc, field, value)
+		} else if qc.QueryType == GEODISTANCE {
+			geoDistance(boolQuery, qc, field, value)
+		} else if qc.QueryType == GEOBOUNDINGBOX {
+			geoBoundingBox(boolQuery, qc, field, value)
+		} else if qc.QueryType == GEOPOLYGON {
+			geoPolygon(boolQuery, qc, field, value)
+		} else if qc.QueryType == FUZZY {
+			fuzzy(boolQuery, qc, field, value)
+		} else if qc.QueryType == REGEXP {
+			regexpQ(boolQuery, qc, field, value)
+		} else if qc.QueryType == IDS {
+			ids(boolQuery, qc, field, value)
+		} else if qc.QueryType == TERM {
+			term(boolQuery, qc, field, value)
+		} else if qc.QueryType == BOOLPREFIX {
+			boolPrefix(boolQuery, qc, field, value)
 		}
 	}
 }
@@ -384,13 +783,7 @@ func querynode(boolQuery *elastic.BoolQuery, qc QueryCond) {
 func exists(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
 	if stringutils.IsNotEmpty(field) {
 		prefixQuery := elastic.NewExistsQuery(field)
-		if qc.QueryLogic == SHOULD {
-			boolQuery.Should(prefixQuery)
-		} else if qc.QueryLogic == MUST {
-			boolQuery.Must(prefixQuery)
-		} else if qc.QueryLogic == MUSTNOT {
-			boolQuery.MustNot(prefixQuery)
-		}
+		attach(boolQuery, qc.QueryLogic, prefixQuery)
 	}
 }
 
@@ -401,13 +794,13 @@ func wildcard(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []
 	}
 	if stringutils.IsNotEmpty(wild) {
 		prefixQuery := elastic.NewWildcardQuery(field, wild)
-		if qc.QueryLogic == SHOULD {
-			boolQuery.Should(prefixQuery)
-		} else if qc.QueryLogic == MUST {
-			boolQuery.Must(prefixQuery)
-		} else if qc.QueryLogic == MUSTNOT {
-			boolQuery.MustNot(prefixQuery)
+		if qc.Boost > 0 {
+			prefixQuery.Boost(qc.Boost)
+		}
+		if qc.CaseInsensitive {
+			prefixQuery.CaseInsensitive(true)
 		}
+		attach(boolQuery, qc.QueryLogic, prefixQuery)
 	}
 }
 
@@ -418,13 +811,13 @@ func prefixQ(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []i
 	}
 	if stringutils.IsNotEmpty(prefix) {
 		prefixQuery := elastic.NewPrefixQuery(field, prefix)
-		if qc.QueryLogic == SHOULD {
-			boolQuery.Should(prefixQuery)
-		} else if qc.QueryLogic == MUST {
-			boolQuery.Must(prefixQuery)
-		} else if qc.QueryLogic == MUSTNOT {
-			boolQuery.MustNot(prefixQuery)
+		if qc.Boost > 0 {
+			prefixQuery.Boost(qc.Boost)
 		}
+		if qc.CaseInsensitive {
+			prefixQuery.CaseInsensitive(true)
+		}
+		attach(boolQuery, qc.QueryLogic, prefixQuery)
 	}
 }
 
@@ -461,18 +854,18 @@ func matchPhrase(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value
 			}
 		}
 	}
-	if qc.QueryLogic == SHOULD {
-		boolQuery.Should(bQuery)
-	} else if qc.QueryLogic == MUST {
-		boolQuery.Must(bQuery)
-	} else if qc.QueryLogic == MUSTNOT {
-		boolQuery.MustNot(bQuery)
+	if qc.Boost > 0 {
+		bQuery.Boost(qc.Boost)
 	}
+	attach(boolQuery, qc.QueryLogic, bQuery)
 }
 
 func rangeQ(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
 	if paramsMap, ok := value[0].(map[string]interface{}); ok {
 		rangeQuery := elastic.NewRangeQuery(field)
+		if qc.Boost > 0 {
+			rangeQuery.Boost(qc.Boost)
+		}
 		if paramsMap["from"] != nil || paramsMap["to"] != nil {
 			if paramsMap["from"] != nil {
 				rangeQuery.From(paramsMap["from"])
@@ -486,59 +879,148 @@ func rangeQ(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []in
 			if paramsMap["include_upper"] != nil {
 				rangeQuery.IncludeUpper(paramsMap["include_upper"].(bool))
 			}
-			if qc.QueryLogic == SHOULD {
-				boolQuery.Should(rangeQuery)
-			} else if qc.QueryLogic == MUST {
-				boolQuery.Must(rangeQuery)
-			} else if qc.QueryLogic == MUSTNOT {
-				boolQuery.MustNot(rangeQuery)
-			}
+			attach(boolQuery, qc.QueryLogic, rangeQuery)
 		}
 	}
 }
 
 func terms(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
 	termsQuery := elastic.NewTermsQuery(field, value...)
-	if qc.QueryLogic == SHOULD {
-		boolQuery.Should(termsQuery)
-	} else if qc.QueryLogic == MUST {
-		boolQuery.Must(termsQuery)
-	} else if qc.QueryLogic == MUSTNOT {
-		boolQuery.MustNot(termsQuery)
+	if qc.Boost > 0 {
+		termsQuery.Boost(qc.Boost)
+	}
+	attach(boolQuery, qc.QueryLogic, termsQuery)
+}
+
+func nested(boolQuery *elastic.BoolQuery, cond QueryCond) {
+	bq := elastic.NewBoolQuery()
+	for _, qc := range cond.Children {
+		querytree(bq, qc)
+	}
+	if stringutils.IsNotEmpty(cond.MinimumShouldMatch) {
+		bq.MinimumShouldMatch(cond.MinimumShouldMatch)
+	}
+	nestedQuery := elastic.NewNestedQuery(cond.Path, bq)
+	if cond.Boost > 0 {
+		nestedQuery.Boost(cond.Boost)
+	}
+	if stringutils.IsNotEmpty(cond.ScoreMode) {
+		nestedQuery.ScoreMode(cond.ScoreMode)
+	}
+	if cond.InnerHits != nil {
+		nestedQuery.InnerHit(cond.InnerHits.innerHit())
+	}
+	attach(boolQuery, cond.QueryLogic, nestedQuery)
+}
+
+func term(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	termQuery := elastic.NewTermQuery(field, value[0])
+	if qc.Boost > 0 {
+		termQuery.Boost(qc.Boost)
+	}
+	if qc.CaseInsensitive {
+		termQuery.CaseInsensitive(true)
+	}
+	attach(boolQuery, qc.QueryLogic, termQuery)
+}
+
+func fuzzy(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	fuzzyQuery := elastic.NewFuzzyQuery(field, value[0])
+	if qc.Boost > 0 {
+		fuzzyQuery.Boost(qc.Boost)
+	}
+	if stringutils.IsNotEmpty(qc.Fuzziness) {
+		fuzzyQuery.Fuzziness(qc.Fuzziness)
+	}
+	if qc.PrefixLength > 0 {
+		fuzzyQuery.PrefixLength(qc.PrefixLength)
+	}
+	if qc.MaxExpansions > 0 {
+		fuzzyQuery.MaxExpansions(qc.MaxExpansions)
+	}
+	attach(boolQuery, qc.QueryLogic, fuzzyQuery)
+}
+
+func regexpQ(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	var re string
+	if len(value) > 0 && value[0] != nil {
+		re, _ = value[0].(string)
+	}
+	if stringutils.IsEmpty(re) {
+		return
+	}
+	regexpQuery := elastic.NewRegexpQuery(field, re)
+	if qc.Boost > 0 {
+		regexpQuery.Boost(qc.Boost)
+	}
+	if stringutils.IsNotEmpty(qc.Flags) {
+		regexpQuery.Flags(qc.Flags)
+	}
+	if qc.CaseInsensitive {
+		regexpQuery.CaseInsensitive(true)
+	}
+	attach(boolQuery, qc.QueryLogic, regexpQuery)
+}
+
+func ids(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	docIds := make([]string, 0, len(value))
+	for _, item := range value {
+		docIds = append(docIds, fmt.Sprintf("%v", item))
+	}
+	idsQuery := elastic.NewIdsQuery().Ids(docIds...)
+	if qc.Boost > 0 {
+		idsQuery.Boost(qc.Boost)
+	}
+	attach(boolQuery, qc.QueryLogic, idsQuery)
+}
+
+// attach adds q to the clause of boolQuery selected by logic
+func attach(boolQuery *elastic.BoolQuery, logic queryLogic, q elastic.Query) {
+	switch logic {
+	case SHOULD:
+		boolQuery.Should(q)
+	case MUST:
+		boolQuery.Must(q)
+	case MUSTNOT:
+		boolQuery.MustNot(q)
+	case FILTER:
+		boolQuery.Filter(q)
 	}
 }
 
 func querytree(boolQuery *elastic.BoolQuery, cond QueryCond) {
+	if cond.QueryType == NESTED {
+		nested(boolQuery, cond)
+		return
+	}
 	if len(cond.Children) > 0 {
 		bq := elastic.NewBoolQuery()
 		for _, qc := range cond.Children {
 			querytree(bq, qc)
 		}
-		if cond.QueryLogic == SHOULD {
-			boolQuery.Should(bq)
-		} else if cond.QueryLogic == MUST {
-			boolQuery.Must(bq)
-		} else if cond.QueryLogic == MUSTNOT {
-			boolQuery.MustNot(bq)
+		if stringutils.IsNotEmpty(cond.MinimumShouldMatch) {
+			bq.MinimumShouldMatch(cond.MinimumShouldMatch)
+		}
+		if cond.Boost > 0 {
+			bq.Boost(cond.Boost)
 		}
+		attach(boolQuery, cond.QueryLogic, bq)
 		return
 	}
 	querynode(boolQuery, cond)
 }
 
-func query(startDate string, endDate string, dateField string, queryConds []QueryCond, zone *time.Location) *elastic.BoolQuery {
+func query(startDate string, endDate string, dateField string, queryConds []QueryCond, minimumShouldMatch string, zone *time.Location) *elastic.BoolQuery {
 	if zone == nil {
 		zone = time.Local
 	}
 	boolQuery := elastic.NewBoolQuery()
-	if dateField != "" && startDate != "" && endDate != "" {
-		boolQuery.Must(
-			elastic.NewRangeQuery(dateField).
-				Gte(startDate).
-				Lt(endDate).
-				Format("yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis").
-				TimeZone(zone.String()),
-		)
+	if dateField != "" && (startDate != "" || endDate != "") {
+		boolQuery.Filter(DateRange{
+			Field: dateField,
+			Start: startDate,
+			End:   endDate,
+		}.rangeQuery(zone))
 	}
 	var hasShould bool
 	for _, qc := range queryConds {
@@ -548,7 +1030,30 @@ func query(startDate string, endDate string, dateField string, queryConds []Quer
 		querytree(boolQuery, qc)
 	}
 	if hasShould {
-		boolQuery.MinimumNumberShouldMatch(1)
+		if stringutils.IsEmpty(minimumShouldMatch) {
+			minimumShouldMatch = "1"
+		}
+		boolQuery.MinimumShouldMatch(minimumShouldMatch)
 	}
 	return boolQuery
 }
+
+func boolPrefix(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	text, _ := value[0].(string)
+	if stringutils.IsEmpty(text) {
+		return
+	}
+	maxShingleSize := qc.MaxShingleSize
+	if maxShingleSize == 0 {
+		maxShingleSize = 3
+	}
+	fields := []string{field}
+	for n := 2; n <= maxShingleSize; n++ {
+		fields = append(fields, fmt.Sprintf("%s._%dgram", field, n))
+	}
+	multiMatchQuery := elastic.NewMultiMatchQuery(text, fields...).Type("bool_prefix")
+	if qc.Boost > 0 {
+		multiMatchQuery.Boost(qc.Boost)
+	}
+	attach(boolQuery, qc.QueryLogic, multiMatchQuery)
+}
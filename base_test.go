@@ -7,103 +7,39 @@ import (
 	"github.com/olivere/elastic/v7"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 	"github.com/unionj-cloud/go-doudou/toolkit/constants"
-	"os"
 	"testing"
 	"time"
 )
 
-// PrepareTestEnvironment prepares test environment
-func PrepareTestEnvironment() (func(), string, int) {
-	var terminateContainer func() // variable to store function to terminate container
-	var host string
-	var port int
-	var err error
-	terminateContainer, host, port, err = SetupEs7Container(logrus.New())
-	if err != nil {
-		panic("failed to setup Elasticsearch container")
-	}
-	return terminateContainer, host, port
-}
-
-// SetupEs7Container starts elasticsearch 7.17.4 docker container
-func SetupEs7Container(logger *logrus.Logger) (func(), string, int, error) {
-	logger.Info("setup Elasticsearch v7 Container")
-	ctx := context.Background()
-
-	req := testcontainers.ContainerRequest{
-		Image:        "docker.elastic.co/elasticsearch/elasticsearch:7.17.4",
-		Name:         "elasticsearch",
-		ExposedPorts: []string{"9200/tcp"},
-		Env: map[string]string{
-			"node.name":             "single-node",
-			"bootstrap.memory_lock": "true",
-			"cluster.name":          "testcontainers-go",
-			"discovery.type":        "single-node",
-			"ES_JAVA_OPTS":          "-Xms1g -Xmx1g",
-		},
-		WaitingFor: wait.ForLog("Cluster health status changed from [YELLOW] to [GREEN]"),
-	}
+var loc, _ = time.LoadLocation("Asia/Shanghai")
 
-	esC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
+// setupSubTest creates an index of the test cluster with test data, it is deleted when t completes
+func setupSubTest(t testing.TB) *Es {
+	t.Helper()
+	es := TestCluster.NewIndex(t, testMapping(), WithLogger(logrus.StandardLogger()))
+	index := es.GetIndex()
+	// restore the index changed by SetIndex so that the created one is deleted
+	t.Cleanup(func() {
+		es.SetIndex(index)
 	})
-
-	if err != nil {
-		logger.Errorf("error starting Elasticsearch container: %s", err)
-		panic(fmt.Sprintf("%v", err))
-	}
-
-	closeContainer := func() {
-		logger.Info("terminating container")
-		err := esC.Terminate(ctx)
-		if err != nil {
-			logger.Errorf("error terminating Elasticsearch container: %s", err)
-			panic(fmt.Sprintf("%v", err))
-		}
-	}
-
-	host, _ := esC.Host(ctx)
-	p, _ := esC.MappedPort(ctx, "9200/tcp")
-	port := p.Int()
-
-	return closeContainer, host, port, nil
+	prepareTestData(es)
+	return es
 }
 
-var esHost string
-var esPort int
-var loc *time.Location
-
-func TestMain(m *testing.M) {
-	os.Setenv("TZ", "Asia/Shanghai")
-	var err error
-	loc, err = time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		panic(err)
+// setupIndex creates an index of the test cluster with mapping and docs, it is deleted when t completes
+func setupIndex(t testing.TB, mapping string, docs ...interface{}) *Es {
+	t.Helper()
+	es := TestCluster.NewIndex(t, mapping, WithLogger(logrus.StandardLogger()))
+	if err := es.BulkSaveOrUpdate(context.Background(), docs); err != nil {
+		t.Fatal(err)
 	}
-	var terminator func()
-	terminator, esHost, esPort = PrepareTestEnvironment()
-	code := m.Run()
-	terminator()
-	os.Exit(code)
-}
-
-func setupSubTest(esindex string) *Es {
-	es := NewEs(esindex, WithLogger(logrus.StandardLogger()), WithUrls([]string{fmt.Sprintf("http://%s:%d", esHost, esPort)}))
-	prepareTestIndex(es)
-	prepareTestData(es)
 	return es
 }
 
-func prepareTestIndex(es *Es) {
-	mapping := NewMapping(MappingPayload{
-		Base{
-			Index: es.esIndex,
-		},
-		[]Field{
+func testMapping() string {
+	return NewMapping(MappingPayload{
+		Fields: []Field{
 			{
 				Name: "createAt",
 				Type: DATE,
@@ -114,10 +50,6 @@ func prepareTestIndex(es *Es) {
 			},
 		},
 	})
-	_, err := es.NewIndex(context.Background(), mapping)
-	if err != nil {
-		panic(err)
-	}
 }
 
 func prepareTestData(es *Es) {
@@ -207,7 +139,7 @@ func Test_query(t *testing.T) {
 					},
 				},
 			},
-			want: `{"bool":{"minimum_should_match":"1","must":[{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-10"}}},{"bool":{"should":{"match_phrase":{"text":{"query":"高考"}}}}},{"terms":{"content":["北京"]}},{"terms":{"content_full":["unionj"]}}],"must_not":{"bool":{"should":{"match_phrase":{"text":{"query":"北京高考"}}}}},"should":[{"bool":{"should":{"match_phrase":{"text":{"query":"考生"}}}}},{"bool":{"should":{"bool":{"must":[{"match_phrase":{"school":{"query":"西安理工"}}},{"match_phrase":{"school":{"query":"西安交大"}}}]}}}},{"bool":{"should":{"bool":{"must":{"match_phrase":{"address":{"query":"北京"}}},"must_not":{"match_phrase":{"address":{"query":"西安"}}}}}}},{"bool":{"should":{"bool":{"must_not":{"match_phrase":{"company":{"query":"unionj"}}}}}}}]}}`,
+			want: `{"bool":{"filter":{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-10"}}},"minimum_should_match":"1","must":[{"bool":{"should":{"match_phrase":{"text":{"query":"高考"}}}}},{"terms":{"content":["北京"]}},{"terms":{"content_full":["unionj"]}}],"must_not":{"bool":{"should":{"match_phrase":{"text":{"query":"北京高考"}}}}},"should":[{"bool":{"should":{"match_phrase":{"text":{"query":"考生"}}}}},{"bool":{"should":{"bool":{"must":[{"match_phrase":{"school":{"query":"西安理工"}}},{"match_phrase":{"school":{"query":"西安交大"}}}]}}}},{"bool":{"should":{"bool":{"must":{"match_phrase":{"address":{"query":"北京"}}},"must_not":{"match_phrase":{"address":{"query":"西安"}}}}}}},{"bool":{"should":{"bool":{"must_not":{"match_phrase":{"company":{"query":"unionj"}}}}}}}]}}`,
 		},
 		{
 			name: "2",
@@ -268,12 +200,12 @@ func Test_query(t *testing.T) {
 					},
 				},
 			},
-			want: `{"bool":{"minimum_should_match":"1","must":[{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-10"}}},{"terms":{"type.keyword":["education"]}},{"terms":{"status":[200]}},{"wildcard":{"position.keyword":{"value":"dev*"}}},{"prefix":{"book.keyword":"go"}}],"must_not":[{"wildcard":{"city.keyword":{"value":"四川*"}}},{"prefix":{"name.keyword":"unionj"}}],"should":[{"wildcard":{"dept.keyword":{"value":"unionj*"}}},{"prefix":{"project.keyword":"unionj"}}]}}`,
+			want: `{"bool":{"filter":{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-10"}}},"minimum_should_match":"1","must":[{"terms":{"type.keyword":["education"]}},{"terms":{"status":[200]}},{"wildcard":{"position.keyword":{"value":"dev*"}}},{"prefix":{"book.keyword":"go"}}],"must_not":[{"wildcard":{"city.keyword":{"value":"四川*"}}},{"prefix":{"name.keyword":"unionj"}}],"should":[{"wildcard":{"dept.keyword":{"value":"unionj*"}}},{"prefix":{"project.keyword":"unionj"}}]}}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, loc)
+			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, "", loc)
 			var src interface{}
 			var err error
 			if src, err = bq.Source(); err != nil {
@@ -285,6 +217,9 @@ func Test_query(t *testing.T) {
 			if !assert.ElementsMatch(t, _src.Path("bool.must").Data(), want.Path("bool.must").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.must").Data(), want.Path("bool.must").Data())
 			}
+			if !assert.Equal(t, _src.Path("bool.filter").Data(), want.Path("bool.filter").Data()) {
+				t.Errorf("query() = %v, want %v", _src.Path("bool.filter").Data(), want.Path("bool.filter").Data())
+			}
 			if !assert.ElementsMatch(t, _src.Path("bool.should").Data(), want.Path("bool.should").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.should").Data(), want.Path("bool.should").Data())
 			}
@@ -351,12 +286,12 @@ func Test_range_query(t *testing.T) {
 					},
 				},
 			},
-			want: `{"bool":{"minimum_should_match":"1","must":[{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},{"range":{"senseResult":{"from":null,"include_lower":true,"include_upper":false,"to":0.4}}},{"terms":{"orderPhrase":[300]}}],"must_not":{"range":{"commonSenseResult":{"from":0.6,"include_lower":true,"include_upper":false,"to":null}}},"should":{"range":{"visitSenseResult":{"from":0.6,"include_lower":true,"include_upper":false,"to":null}}}}}`,
+			want: `{"bool":{"filter":{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},"minimum_should_match":"1","must":[{"range":{"senseResult":{"from":null,"include_lower":true,"include_upper":false,"to":0.4}}},{"terms":{"orderPhrase":[300]}}],"must_not":{"range":{"commonSenseResult":{"from":0.6,"include_lower":true,"include_upper":false,"to":null}}},"should":{"range":{"visitSenseResult":{"from":0.6,"include_lower":true,"include_upper":false,"to":null}}}}}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, loc)
+			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, "", loc)
 			var src interface{}
 			var err error
 			if src, err = bq.Source(); err != nil {
@@ -368,6 +303,9 @@ func Test_range_query(t *testing.T) {
 			if !assert.ElementsMatch(t, _src.Path("bool.must").Data(), want.Path("bool.must").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.must").Data(), want.Path("bool.must").Data())
 			}
+			if !assert.Equal(t, _src.Path("bool.filter").Data(), want.Path("bool.filter").Data()) {
+				t.Errorf("query() = %v, want %v", _src.Path("bool.filter").Data(), want.Path("bool.filter").Data())
+			}
 			if !assert.Equal(t, _src.Path("bool.should").Data(), want.Path("bool.should").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.should").Data(), want.Path("bool.should").Data())
 			}
@@ -424,12 +362,12 @@ func Test_exists_query(t *testing.T) {
 					},
 				},
 			},
-			want: `{"bool":{"minimum_should_match":"1","must":[{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},{"exists":{"field":"flag"}},{"terms":{"orderPhrase":[300]}}],"must_not":{"exists":{"field":"delete_at"}},"should":{"exists":{"field":"status"}}}}`,
+			want: `{"bool":{"filter":{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},"minimum_should_match":"1","must":[{"exists":{"field":"flag"}},{"terms":{"orderPhrase":[300]}}],"must_not":{"exists":{"field":"delete_at"}},"should":{"exists":{"field":"status"}}}}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, loc)
+			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, "", loc)
 			var src interface{}
 			var err error
 			if src, err = bq.Source(); err != nil {
@@ -441,6 +379,9 @@ func Test_exists_query(t *testing.T) {
 			if !assert.ElementsMatch(t, _src.Path("bool.must").Data(), want.Path("bool.must").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.must").Data(), want.Path("bool.must").Data())
 			}
+			if !assert.Equal(t, _src.Path("bool.filter").Data(), want.Path("bool.filter").Data()) {
+				t.Errorf("query() = %v, want %v", _src.Path("bool.filter").Data(), want.Path("bool.filter").Data())
+			}
 			if !assert.Equal(t, _src.Path("bool.should").Data(), want.Path("bool.should").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.should").Data(), want.Path("bool.should").Data())
 			}
@@ -502,12 +443,12 @@ func Test_children_query(t *testing.T) {
 					},
 				},
 			},
-			want: `{"bool":{"must":{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},"must_not":[{"exists":{"field":"delete_at"}},{"terms":{"status":[100,300]}},{"bool":{"must":[{"terms":{"type":["网络调查"]}},{"terms":{"price":[0]}}]}}]}}`,
+			want: `{"bool":{"filter":{"range":{"acceptDate":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"2020-07-01"}}},"must_not":[{"exists":{"field":"delete_at"}},{"terms":{"status":[100,300]}},{"bool":{"must":[{"terms":{"type":["网络调查"]}},{"terms":{"price":[0]}}]}}]}}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, loc)
+			bq := query(tt.args.startDate, tt.args.endDate, tt.args.dateField, tt.args.queryConds, "", loc)
 			var src interface{}
 			var err error
 			if src, err = bq.Source(); err != nil {
@@ -519,6 +460,9 @@ func Test_children_query(t *testing.T) {
 			if !assert.Equal(t, _src.Path("bool.must").Data(), want.Path("bool.must").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.must").Data(), want.Path("bool.must").Data())
 			}
+			if !assert.Equal(t, _src.Path("bool.filter").Data(), want.Path("bool.filter").Data()) {
+				t.Errorf("query() = %v, want %v", _src.Path("bool.filter").Data(), want.Path("bool.filter").Data())
+			}
 			if !assert.ElementsMatch(t, _src.Path("bool.must_not").Data(), want.Path("bool.must_not").Data()) {
 				t.Errorf("query() = %v, want %v", _src.Path("bool.must_not").Data(), want.Path("bool.must_not").Data())
 			}
@@ -529,6 +473,45 @@ func Test_children_query(t *testing.T) {
 	}
 }
 
+func Test_query_minimumShouldMatch(t *testing.T) {
+	queryConds := []QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"type": {"education"},
+			},
+			QueryLogic: MUST,
+			QueryType:  TERMS,
+		},
+		{
+			Pair: map[string][]interface{}{
+				"text": {"考生", "考场"},
+			},
+			QueryLogic: SHOULD,
+			QueryType:  MATCHPHRASE,
+		},
+	}
+	tests := []struct {
+		name               string
+		queryConds         []QueryCond
+		minimumShouldMatch string
+		want               interface{}
+	}{
+		{"default", queryConds, "", "1"},
+		{"custom", queryConds, "0", "0"},
+		{"percentage", queryConds, "75%", "75%"},
+		{"no should", queryConds[:1], "2", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := query("", "", "", tt.queryConds, tt.minimumShouldMatch, loc).Source()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, gabs.Wrap(src).Path("bool.minimum_should_match").Data())
+		})
+	}
+}
+
 func TestNewEs(t *testing.T) {
 	url := "http://test.com"
 	username := "unionj"
@@ -615,3 +598,177 @@ func TestPaging_String(t *testing.T) {
 	}
 	fmt.Println(p.String())
 }
+
+func Test_nested_query(t *testing.T) {
+	queryConds := []QueryCond{
+		{
+			QueryLogic: MUST,
+			QueryType:  NESTED,
+			Path:       "line",
+			ScoreMode:  "max",
+			InnerHits: &InnerHits{
+				Size: 3,
+			},
+			Children: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"line.sku": {"A001"},
+					},
+					QueryLogic: MUST,
+					QueryType:  TERMS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"line.qty": {map[string]interface{}{
+							"from": float64(2),
+						}},
+					},
+					QueryLogic: MUST,
+					QueryType:  RANGE,
+				},
+			},
+		},
+	}
+	want := `{"bool":{"must":{"nested":{"inner_hits":{"size":3},"path":"line","query":{"bool":{"must":[{"terms":{"line.sku":["A001"]}},{"range":{"line.qty":{"from":2,"include_lower":true,"include_upper":true,"to":null}}}]}},"score_mode":"max"}}}}`
+	bq := query("", "", "", queryConds, "", loc)
+	var src interface{}
+	var err error
+	if src, err = bq.Source(); err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, want, gabs.Wrap(src).String())
+}
+
+func Test_geo_query(t *testing.T) {
+	queryConds := []QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"location": {map[string]interface{}{
+					"lat":      float64(40),
+					"lon":      float64(-70),
+					"distance": "200km",
+				}},
+			},
+			QueryLogic: MUST,
+			QueryType:  GEODISTANCE,
+		},
+		{
+			Pair: map[string][]interface{}{
+				"location": {map[string]interface{}{
+					"top_left":     GeoPoint{Lat: 40.73, Lon: -74.1},
+					"bottom_right": "40.01,-71.12",
+				}},
+			},
+			QueryLogic: SHOULD,
+			QueryType:  GEOBOUNDINGBOX,
+		},
+		{
+			Pair: map[string][]interface{}{
+				"location": {
+					map[string]interface{}{"lat": float64(40), "lon": float64(-70)},
+					[]interface{}{float64(-80), float64(30)},
+					"20,-90",
+				},
+			},
+			QueryLogic: MUSTNOT,
+			QueryType:  GEOPOLYGON,
+		},
+	}
+	want := `{"bool":{"minimum_should_match":"1","must":{"geo_distance":{"distance":"200km","location":{"lat":40,"lon":-70}}},"must_not":{"geo_polygon":{"location":{"points":[{"lat":40,"lon":-70},{"lat":30,"lon":-80},{"lat":20,"lon":-90}]}}},"should":{"geo_bounding_box":{"location":{"bottom_right":[-71.12,40.01],"top_left":[-74.1,40.73]}}}}}`
+	bq := query("", "", "", queryConds, "", loc)
+	var src interface{}
+	var err error
+	if src, err = bq.Source(); err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, want, gabs.Wrap(src).String())
+}
+
+func Test_term_fuzzy_regexp_ids_query(t *testing.T) {
+	queryConds := []QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"status": {"Open"},
+			},
+			QueryLogic:      MUST,
+			QueryType:       TERM,
+			CaseInsensitive: true,
+		},
+		{
+			Pair: map[string][]interface{}{
+				"product": {"iphnoe"},
+			},
+			QueryLogic:    SHOULD,
+			QueryType:     FUZZY,
+			Fuzziness:     "AUTO",
+			PrefixLength:  1,
+			MaxExpansions: 20,
+		},
+		{
+			Pair: map[string][]interface{}{
+				"sku": {"A[0-9]+"},
+			},
+			QueryLogic: MUST,
+			QueryType:  REGEXP,
+			Flags:      "ALL",
+		},
+		{
+			Pair: map[string][]interface{}{
+				"_id": {"1", 2},
+			},
+			QueryLogic: MUSTNOT,
+			QueryType:  IDS,
+		},
+	}
+	want := `{"bool":{"minimum_should_match":"1","must":[{"term":{"status":{"case_insensitive":true,"value":"Open"}}},{"regexp":{"sku":{"flags":"ALL","value":"A[0-9]+"}}}],"must_not":{"ids":{"values":["1","2"]}},"should":{"fuzzy":{"product":{"fuzziness":"AUTO","max_expansions":20,"prefix_length":1,"value":"iphnoe"}}}}}`
+	bq := query("", "", "", queryConds, "", loc)
+	var src interface{}
+	var err error
+	if src, err = bq.Source(); err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, want, gabs.Wrap(src).String())
+}
+
+func Test_filter_boost_query(t *testing.T) {
+	queryConds := []QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"type": {"education"},
+			},
+			QueryLogic: FILTER,
+			QueryType:  TERMS,
+		},
+		{
+			QueryLogic:         SHOULD,
+			MinimumShouldMatch: "2",
+			Boost:              2,
+			Children: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"text": {"考生"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  MATCHPHRASE,
+					Boost:      3,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"school": {"西安"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  PREFIX,
+					Boost:      0.5,
+				},
+			},
+		},
+	}
+	want := `{"bool":{"filter":{"terms":{"type":["education"]}},"minimum_should_match":"1","should":{"bool":{"boost":2,"minimum_should_match":"2","should":[{"bool":{"boost":3,"should":{"match_phrase":{"text":{"query":"考生"}}}}},{"prefix":{"school":{"boost":0.5,"value":"西安"}}}]}}}}`
+	bq := query("", "", "", queryConds, "", loc)
+	var src interface{}
+	var err error
+	if src, err = bq.Source(); err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, want, gabs.Wrap(src).String())
+}
@@ -7,7 +7,12 @@ import (
 )
 
 // BulkDelete delete es docs specified by ids in bulk
-func (es *Es) BulkDelete(ctx context.Context, ids []string) error {
+func (es *Es) BulkDelete(ctx context.Context, ids []string) (err error) {
+	ctx, op, err := es.begin(ctx, "BulkDelete", &Call{Write: true, IDs: ids})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
 	bulkRequest := es.client.Bulk().Index(es.esIndex).Type(es.esType)
 
 	for _, id := range ids {
@@ -16,14 +21,14 @@ func (es *Es) BulkDelete(ctx context.Context, ids []string) error {
 
 	var (
 		bulkRes *elastic.BulkResponse
-		err     error
 	)
 
 	if bulkRes, err = bulkRequest.Do(ctx); err != nil {
 		return errors.Wrap(err, "call Bulk() error")
 	}
+	recordBulk(ctx, bulkRes)
 	if bulkRes.Errors {
-		return errors.New("bulk partially failed")
+		return newBulkError("bulk partially failed", bulkRes)
 	}
 
 	es.client.Flush(es.esIndex).Do(ctx)
@@ -28,8 +28,32 @@ func getId(doc interface{}) (string, error) {
 	return "", nil
 }
 
+// BulkError is returned by bulk operations when some items failed
+type BulkError struct {
+	msg string
+	// Failed are the failed items of the bulk response
+	Failed []*elastic.BulkResponseItem
+}
+
+func newBulkError(msg string, res *elastic.BulkResponse) *BulkError {
+	return &BulkError{
+		msg:    msg,
+		Failed: res.Failed(),
+	}
+}
+
+func (e *BulkError) Error() string {
+	return e.msg
+}
+
 // BulkSaveOrUpdate save or update docs in bulk
-func (es *Es) BulkSaveOrUpdate(ctx context.Context, docs []interface{}) error {
+func (es *Es) BulkSaveOrUpdate(ctx context.Context, docs []interface{}) (err error) {
+	ctx, op, err := es.begin(ctx, "BulkSaveOrUpdate", &Call{Write: true, Docs: docs})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
+	docs = op.call.Docs
 	bulkRequest := es.client.Bulk().Index(es.esIndex).Type(es.esType)
 
 	for _, doc := range docs {
@@ -47,16 +71,16 @@ func (es *Es) BulkSaveOrUpdate(ctx context.Context, docs []interface{}) error {
 
 	var (
 		bulkRes *elastic.BulkResponse
-		err     error
 	)
 
 	if bulkRes, err = bulkRequest.Do(ctx); err != nil {
 		return errors.Wrap(err, "call Bulk() error")
 	}
+	recordBulk(ctx, bulkRes)
 	if bulkRes.Errors {
 		for _, item := range bulkRes.Items {
 			if item["index"].Error != nil {
-				return errors.New(item["index"].Error.Reason)
+				return newBulkError(item["index"].Error.Reason, bulkRes)
 			}
 		}
 	}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestBulkSaveOrUpdate(t *testing.T) {
-	es := setupSubTest("test_bulksaveorupdate")
+	es := setupSubTest(t)
 	type args struct {
 		docs []interface{}
 	}
@@ -7,9 +7,13 @@ import (
 )
 
 // ClearIndex remove all docs
-func (es *Es) ClearIndex(ctx context.Context) error {
+func (es *Es) ClearIndex(ctx context.Context) (err error) {
+	ctx, op, err := es.begin(ctx, "ClearIndex", &Call{Write: true})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
 	var (
-		err error
 		res *elastic.BulkIndexByScrollResponse
 	)
 
@@ -6,7 +6,7 @@ import (
 )
 
 func TestClearIndex(t *testing.T) {
-	es := setupSubTest("test_clearindex")
+	es := setupSubTest(t)
 	tests := []struct {
 		name    string
 		wantErr bool
@@ -0,0 +1,43 @@
+package esutils
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// EsClient is implemented by Es and FakeEs, depend on it instead of *Es to unit test code without es
+type EsClient interface {
+	// SaveOrUpdate saves or updates doc
+	SaveOrUpdate(ctx context.Context, doc interface{}) (string, error)
+	// BulkSaveOrUpdate save or update docs in bulk
+	BulkSaveOrUpdate(ctx context.Context, docs []interface{}) error
+	// GetByID gets a doc by id
+	GetByID(ctx context.Context, id string) (map[string]interface{}, error)
+	// BulkDelete delete es docs specified by ids in bulk
+	BulkDelete(ctx context.Context, ids []string) error
+	// List fetch docs by paging
+	List(ctx context.Context, paging *Paging, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error)
+	// Page fetch pagination result
+	Page(ctx context.Context, paging *Paging) (PageResult, error)
+	// Count counts docs by paging
+	Count(ctx context.Context, paging *Paging) (int64, error)
+	// Stat aggr only accept map[string]interface{} or elastic.Aggregation
+	Stat(ctx context.Context, paging *Paging, aggr interface{}) (map[string]interface{}, error)
+	// NewIndex creates a new index
+	NewIndex(ctx context.Context, mapping string) (bool, error)
+	// NewIndexOnly creates a new index without settings and mappings
+	NewIndexOnly(ctx context.Context) (bool, error)
+	// DeleteIndex removes the index
+	DeleteIndex(ctx context.Context) error
+	// ClearIndex remove all docs
+	ClearIndex(ctx context.Context) error
+	// PutMapping updates mapping
+	PutMapping(ctx context.Context, mp MappingPayload) error
+	// PutMappingJson updates mapping with json data
+	PutMappingJson(ctx context.Context, mapping string) error
+	// GetMapping get mapping
+	GetMapping(ctx context.Context) (map[string]interface{}, error)
+}
+
+var _ EsClient = (*Es)(nil)
+var _ EsClient = (*FakeEs)(nil)
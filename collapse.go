@@ -0,0 +1,36 @@
+package esutils
+
+import (
+	"github.com/olivere/elastic/v7"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// Collapse deduplicates hits by a keyword or numeric field, only the top hit of each group is returned
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/collapse-search-results.html
+type Collapse struct {
+	Field string `json:"field"`
+	// InnerHits returns docs of each group in _inner_hits of the top hit, Name defaults to Field
+	InnerHits *InnerHits `json:"innerHits"`
+	// MaxConcurrentGroupSearches limits concurrent requests for inner hits of groups
+	MaxConcurrentGroupSearches int `json:"maxConcurrentGroupSearches"`
+}
+
+func (c *Collapse) builder() *elastic.CollapseBuilder {
+	builder := elastic.NewCollapseBuilder(c.Field)
+	if c.InnerHits != nil {
+		ih := *c.InnerHits
+		if stringutils.IsEmpty(ih.Name) {
+			ih.Name = c.Field
+		}
+		builder = builder.InnerHit(ih.innerHit())
+	}
+	if c.MaxConcurrentGroupSearches > 0 {
+		builder = builder.MaxConcurrentGroupRequests(c.MaxConcurrentGroupSearches)
+	}
+	return builder
+}
+
+// groupsAgg counts distinct values of the collapse field, the count is approximate if it is greater than 3000
+func (c *Collapse) groupsAgg() *elastic.CardinalityAggregation {
+	return elastic.NewCardinalityAggregation().Field(c.Field)
+}
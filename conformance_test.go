@@ -0,0 +1,87 @@
+package esutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// conformanceMapping maps fields of fakeDocs the way FakeEs treats them
+const conformanceMapping = `{"mappings":{"_doc":{"properties":{
+"createAt":{"type":"date"},
+"type":{"type":"text","fields":{"keyword":{"type":"keyword"}}},
+"text":{"type":"text"},
+"status":{"type":"keyword"},
+"score":{"type":"float"},
+"tags":{"type":"keyword"},
+"product":{"type":"keyword"},
+"location":{"type":"geo_point"},
+"line":{"type":"nested","properties":{"sku":{"type":"keyword"},"qty":{"type":"integer"}}}
+}}}}`
+
+// TestFakeEs_conformance checks FakeEs matches, counts and pages the same docs as es
+func TestFakeEs_conformance(t *testing.T) {
+	es := setupIndex(t, conformanceMapping, fakeDocs()...)
+	fake := setupFakeEs(t)
+	ctx := context.Background()
+	// FakeEs splits text into words instead of analyzing it like es
+	analyzed := map[string]bool{
+		"match phrase":                     true,
+		"match phrase with plus and minus": true,
+		"bool prefix":                      true,
+	}
+	for _, tt := range fakeQueryTests() {
+		if analyzed[tt.name] {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			tt.paging.Limit = -1
+			want, err := es.List(ctx, tt.paging, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := fake.List(ctx, tt.paging, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.ElementsMatch(t, fakeIds(want), fakeIds(got))
+			wantCount, err := es.Count(ctx, tt.paging)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotCount, err := fake.Count(ctx, tt.paging)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, wantCount, gotCount)
+		})
+	}
+
+	pagings := []*Paging{
+		{Sortby: []Sort{{Field: "score", Ascending: false}}, Limit: 2},
+		{Sortby: []Sort{{Field: "score", Ascending: false}}, Skip: 2, Limit: 2},
+		{Sortby: []Sort{{Field: "score", Ascending: false}}, SearchAfter: []interface{}{80}, Limit: 2},
+	}
+	for _, paging := range pagings {
+		want, err := es.Page(ctx, paging)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := fake.Page(ctx, paging)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, fakeIds(want.Docs), fakeIds(got.Docs))
+		assert.Equal(t, want.Total, got.Total)
+		assert.Equal(t, want.HasNextPage, got.HasNextPage)
+	}
+
+	collapse := &Paging{Limit: -1, Collapse: &Collapse{Field: "type.keyword"}}
+	_, wantErr := es.Page(ctx, collapse)
+	_, gotErr := fake.Page(ctx, collapse)
+	if assert.Error(t, wantErr) && assert.Error(t, gotErr) {
+		assert.Equal(t, errors.Cause(wantErr).Error(), errors.Cause(gotErr).Error())
+	}
+}
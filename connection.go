@@ -0,0 +1,325 @@
+package esutils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// WithCACert trusts the PEM encoded CA bundle at path besides system roots, e.g. for clusters with self-signed certificates
+func WithCACert(path string) EsOption {
+	return func(es *Es) {
+		es.caCert = path
+	}
+}
+
+// WithClientCert authenticates to es by the PEM encoded client certificate and key
+func WithClientCert(certFile, keyFile string) EsOption {
+	return func(es *Es) {
+		es.certFile = certFile
+		es.keyFile = keyFile
+	}
+}
+
+// WithInsecureSkipVerify skips verification of es certificates, only for development
+func WithInsecureSkipVerify(skip bool) EsOption {
+	return func(es *Es) {
+		es.insecureSkipVerify = skip
+	}
+}
+
+// WithTLSConfig sets base tls config, WithCACert, WithClientCert and WithInsecureSkipVerify are applied on a copy of it
+func WithTLSConfig(config *tls.Config) EsOption {
+	return func(es *Es) {
+		es.tlsConfig = config
+	}
+}
+
+// WithAPIKey authenticates to es by the base64 encoded api key, i.e. the encoded field returned by create api key api
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/security-api-create-api-key.html
+func WithAPIKey(apiKey string) EsOption {
+	return func(es *Es) {
+		es.apiKey = apiKey
+	}
+}
+
+// WithBearerToken authenticates to es by the oauth2 access token
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/token-authentication-services.html
+func WithBearerToken(token string) EsOption {
+	return func(es *Es) {
+		es.bearerToken = token
+	}
+}
+
+// WithCloudID connects to the Elastic Cloud deployment identified by cloudID, urls are ignored
+// https://www.elastic.co/guide/en/cloud/current/ec-cloud-id.html
+func WithCloudID(cloudID string) EsOption {
+	return func(es *Es) {
+		es.cloudID = cloudID
+	}
+}
+
+// WithHeader adds a header to every request
+func WithHeader(key, value string) EsOption {
+	return func(es *Es) {
+		if es.headers == nil {
+			es.headers = make(http.Header)
+		}
+		es.headers.Add(key, value)
+	}
+}
+
+// WithHTTPClient sets http client used by the default elastic client, e.g. for proxy or timeout
+func WithHTTPClient(client *http.Client) EsOption {
+	return func(es *Es) {
+		es.httpClient = client
+	}
+}
+
+// WithTransport sets transport of the http client used by the default elastic client
+func WithTransport(transport http.RoundTripper) EsOption {
+	return func(es *Es) {
+		es.transport = transport
+	}
+}
+
+// WithSniff enables sniffing nodes of the cluster from urls at startup and every interval, interval <= 0 means
+// elastic's default 15m. Don't enable it if nodes publish addresses unreachable from the client, e.g. in docker
+func WithSniff(enabled bool, interval time.Duration) EsOption {
+	return func(es *Es) {
+		es.sniff = enabled
+		es.sniffInterval = interval
+	}
+}
+
+// WithHealthcheck enables checking health of nodes at startup and every interval, interval <= 0 means
+// elastic's default 60s. Dead nodes are skipped until they are healthy again
+func WithHealthcheck(enabled bool, interval time.Duration) EsOption {
+	return func(es *Es) {
+		es.healthcheck = enabled
+		es.healthcheckInterval = interval
+	}
+}
+
+// WithRetry retries requests failed by connection errors or status codes of policy on the next node
+func WithRetry(policy RetryPolicy) EsOption {
+	return func(es *Es) {
+		es.retryPolicy = &policy
+	}
+}
+
+// WithRequestTimeout sets timeout of each http request to es, retries have their own timeout
+func WithRequestTimeout(timeout time.Duration) EsOption {
+	return func(es *Es) {
+		es.requestTimeout = timeout
+	}
+}
+
+// DefaultRetryStatusCodes are status codes retried by RetryPolicy if StatusCodes is empty
+var DefaultRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy retries failed requests with exponential backoff and jitter
+type RetryPolicy struct {
+	// MaxRetries is max number of retries of a request, default is 3
+	MaxRetries int `json:"maxRetries"`
+	// InitialInterval is the wait before the first retry, default is 100ms
+	InitialInterval time.Duration `json:"initialInterval"`
+	// MaxInterval caps the wait between retries, default is 5s
+	MaxInterval time.Duration `json:"maxInterval"`
+	// StatusCodes are retried besides connection errors, default is DefaultRetryStatusCodes
+	StatusCodes []int `json:"statusCodes"`
+}
+
+func (p RetryPolicy) statusCodes() []int {
+	if len(p.StatusCodes) == 0 {
+		return DefaultRetryStatusCodes
+	}
+	return p.StatusCodes
+}
+
+// retrier implements elastic.Retrier by RetryPolicy
+type retrier struct {
+	policy RetryPolicy
+}
+
+// Retry is called by elastic with retry starting from 1
+func (r retrier) Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	maxRetries, initial, max := r.policy.MaxRetries, r.policy.InitialInterval, r.policy.MaxInterval
+	if maxRetries <= 0 {
+		maxRetries = 3
+	}
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+	if retry > maxRetries || ctx.Err() != nil {
+		return 0, false, nil
+	}
+	wait := initial << uint(retry-1)
+	if wait <= 0 || wait > max {
+		wait = max
+	}
+	// jitter in [wait/2, wait] spreads retries of concurrent requests
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	if resp != nil && resp.Body != nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return wait, true, nil
+}
+
+// cloudURL decodes es url from Elastic Cloud ID like name:base64(host$es_uuid$kibana_uuid)
+func cloudURL(cloudID string) (string, error) {
+	encoded := cloudID
+	if i := strings.LastIndex(cloudID, ":"); i >= 0 {
+		encoded = cloudID[i+1:]
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.Wrap(err, "call DecodeString() error")
+	}
+	parts := strings.Split(string(decoded), "$")
+	if len(parts) < 2 || stringutils.IsEmpty(parts[0]) || stringutils.IsEmpty(parts[1]) {
+		return "", errors.Errorf("invalid cloud id %q", cloudID)
+	}
+	host, port := parts[0], "443"
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host, port = host[:i], host[i+1:]
+	}
+	esUUID := parts[1]
+	if i := strings.LastIndex(esUUID, ":"); i >= 0 {
+		esUUID, port = esUUID[:i], esUUID[i+1:]
+	}
+	return fmt.Sprintf("https://%s.%s:%s", esUUID, host, port), nil
+}
+
+func (e *Es) hasTLSOptions() bool {
+	return e.tlsConfig != nil || stringutils.IsNotEmpty(e.caCert) || stringutils.IsNotEmpty(e.certFile) || e.insecureSkipVerify
+}
+
+func (e *Es) newTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if e.tlsConfig != nil {
+		config = e.tlsConfig.Clone()
+	}
+	if stringutils.IsNotEmpty(e.caCert) {
+		pem, err := ioutil.ReadFile(e.caCert)
+		if err != nil {
+			return nil, errors.Wrap(err, "call ReadFile() error")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", e.caCert)
+		}
+		config.RootCAs = pool
+	}
+	if stringutils.IsNotEmpty(e.certFile) || stringutils.IsNotEmpty(e.keyFile) {
+		cert, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "call LoadX509KeyPair() error")
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if e.insecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
+
+// warnClientOptions logs options installed on the http client of the default elastic client, which are ignored by
+// the client of WithClient
+func (e *Es) warnClientOptions() {
+	if e.tracerProvider != nil {
+		e.logger.Errorf("WithTracerProvider: http requests of the client of WithClient have no spans, only operations have")
+	}
+	if e.slowQueryThreshold > 0 {
+		e.logger.Errorf("WithSlowQueryThreshold: request bodies of the client of WithClient aren't captured, slow queries are logged without them")
+	}
+}
+
+// newHTTPClient returns the http client for the default elastic client, nil means elastic's default
+func (e *Es) newHTTPClient() (*http.Client, error) {
+	if e.httpClient == nil && e.transport == nil && !e.hasTLSOptions() && e.requestTimeout <= 0 && e.tracerProvider == nil &&
+		e.slowQueryThreshold <= 0 && len(e.hooks) == 0 && !e.traceLog {
+		return nil, nil
+	}
+	client := &http.Client{}
+	if e.httpClient != nil {
+		c := *e.httpClient
+		client = &c
+	}
+	transport := e.transport
+	if transport == nil {
+		transport = client.Transport
+	}
+	if e.hasTLSOptions() {
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		t, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, errors.Errorf("tls options require *http.Transport, got %T", transport)
+		}
+		config, err := e.newTLSConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "call newTLSConfig() error")
+		}
+		t = t.Clone()
+		t.TLSClientConfig = config
+		transport = t
+	}
+	if e.traceLog {
+		transport = traceTransport{next: transport, logf: tracef(e.logger)}
+	}
+	if len(e.hooks) > 0 {
+		transport = headerTransport{next: transport}
+	}
+	if e.slowQueryThreshold > 0 {
+		transport = capturingTransport{next: transport}
+	}
+	if e.tracerProvider != nil {
+		transport = tracingTransport{next: transport, tracer: e.tracer()}
+	}
+	client.Transport = transport
+	if e.requestTimeout > 0 {
+		client.Timeout = e.requestTimeout
+	}
+	return client, nil
+}
+
+// newHeaders returns default headers of every request including api key or bearer token authorization
+func (e *Es) newHeaders() (http.Header, error) {
+	headers := make(http.Header)
+	for key, values := range e.headers {
+		headers[key] = append([]string(nil), values...)
+	}
+	if stringutils.IsNotEmpty(e.apiKey) && stringutils.IsNotEmpty(e.bearerToken) {
+		return nil, errors.New("api key and bearer token cannot be used together")
+	}
+	if (stringutils.IsNotEmpty(e.apiKey) || stringutils.IsNotEmpty(e.bearerToken)) && stringutils.IsNotEmpty(e.username) {
+		return nil, errors.New("basic auth cannot be used together with api key or bearer token")
+	}
+	if stringutils.IsNotEmpty(e.apiKey) {
+		headers.Set("Authorization", "ApiKey "+e.apiKey)
+	}
+	if stringutils.IsNotEmpty(e.bearerToken) {
+		headers.Set("Authorization", "Bearer "+e.bearerToken)
+	}
+	return headers, nil
+}
@@ -0,0 +1,93 @@
+package esutils
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cloudURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cloudID string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default port",
+			// my-deployment:base64("us-east-1.aws.found.io$abc123$def456")
+			cloudID: "my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbyRhYmMxMjMkZGVmNDU2",
+			want:    "https://abc123.us-east-1.aws.found.io:443",
+		},
+		{
+			name: "custom port",
+			// base64("us-east-1.aws.found.io:9243$abc123$def456")
+			cloudID: "my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbzo5MjQzJGFiYzEyMyRkZWY0NTY=",
+			want:    "https://abc123.us-east-1.aws.found.io:9243",
+		},
+		{
+			name:    "invalid",
+			cloudID: "my-deployment:bm90LWEtY2xvdWQtaWQ=",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cloudURL(tt.cloudID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("cloudURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewEs_tlsAndAPIKey(t *testing.T) {
+	var header http.Header
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tagline":"You Know, for Search"}`))
+	}))
+	defer server.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	err := ioutil.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	es := NewEs("test_tls", WithUrls([]string{server.URL}), WithCACert(caCert), WithAPIKey("aWQ6a2V5"), WithHeader("X-Tenant", "unionj"))
+	_, err = es.client.PerformRequest(context.Background(), elastic.PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ApiKey aWQ6a2V5", header.Get("Authorization"))
+	assert.Equal(t, "unionj", header.Get("X-Tenant"))
+
+	es = NewEs("test_tls", WithUrls([]string{server.URL}), WithBearerToken("token"))
+	_, err = es.client.PerformRequest(context.Background(), elastic.PerformRequestOptions{Method: "GET", Path: "/"})
+	assert.Error(t, err, "certificate of the server is not trusted")
+
+	es = NewEs("test_tls", WithUrls([]string{server.URL}), WithInsecureSkipVerify(true), WithBearerToken("token"))
+	_, err = es.client.PerformRequest(context.Background(), elastic.PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+}
+
+func TestEs_newHeaders(t *testing.T) {
+	_, err := NewEs("test_tls", WithClient(&elastic.Client{}), WithAPIKey("key"), WithBearerToken("token")).newHeaders()
+	assert.Error(t, err)
+	_, err = NewEs("test_tls", WithClient(&elastic.Client{}), WithAPIKey("key"), WithUsername("elastic")).newHeaders()
+	assert.Error(t, err)
+}
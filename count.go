@@ -9,9 +9,14 @@ import (
 )
 
 // Count counts docs by paging
-func (es *Es) Count(ctx context.Context, paging *Paging) (int64, error) {
+func (es *Es) Count(ctx context.Context, paging *Paging) (total int64, err error) {
+	ctx, op, err := es.begin(ctx, "Count", &Call{Paging: paging})
+	defer func() { err = op.end(total, err) }()
+	if err != nil {
+		return 0, err
+	}
+	paging = op.call.Paging
 	var (
-		err       error
 		boolQuery *elastic.BoolQuery
 	)
 	if paging == nil {
@@ -24,10 +29,11 @@ func (es *Es) Count(ctx context.Context, paging *Paging) (int64, error) {
 			return 0, errors.Wrap(err, "call LoadLocation() error")
 		}
 	}
-	boolQuery = query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, zone)
+	if boolQuery, err = pagingQuery(paging, zone); err != nil {
+		return 0, errors.Wrap(err, "call pagingQuery() error")
+	}
 	es.client.Refresh().Index(es.esIndex).Do(ctx)
 	es.client.Flush().Index(es.esIndex).Do(ctx)
-	var total int64
 	if total, err = es.client.Count().Index(es.esIndex).Type(es.esType).Query(boolQuery).Do(ctx); err != nil {
 		return 0, errors.Wrap(err, "call Count() error")
 	}
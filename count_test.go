@@ -7,7 +7,7 @@ import (
 )
 
 func TestEs_Count(t *testing.T) {
-	es := setupSubTest("test_count")
+	es := setupSubTest(t)
 	count, _ := es.Count(context.Background(), nil)
 	assert.EqualValues(t, 3, count)
 }
@@ -0,0 +1,216 @@
+package esutils
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// DefaultDateFormat is the format of Paging.StartDate, Paging.EndDate and DateRange if Format is not set
+const DefaultDateFormat = "yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis"
+
+// DateRange filters docs by a date window on Field in a filter clause. Either Start or End can be empty for an open-ended window.
+// Start and End accept dates in Format and date math expressions like now-7d/d or 2020-06-01||+1M/d
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/common-options.html#date-math
+type DateRange struct {
+	Field string `json:"field"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// ExcludeStart uses gt instead of gte for Start
+	ExcludeStart bool `json:"excludeStart"`
+	// IncludeEnd uses lte instead of lt for End
+	IncludeEnd bool `json:"includeEnd"`
+	// Format is es date format, default is DefaultDateFormat
+	Format string `json:"format"`
+}
+
+var dateMathExpr = regexp.MustCompile(`^([+-]\d+[yMwdhHms]|/[yMwdhHms])*$`)
+
+// check returns an error if the window is empty or any bound cannot be parsed
+func (r DateRange) check() error {
+	if stringutils.IsEmpty(r.Field) {
+		return errors.New("date range must have field")
+	}
+	if stringutils.IsEmpty(r.Start) && stringutils.IsEmpty(r.End) {
+		return errors.Errorf("date range on %s must have start or end", r.Field)
+	}
+	for _, value := range []string{r.Start, r.End} {
+		if stringutils.IsEmpty(value) {
+			continue
+		}
+		if err := checkDate(value, r.format()); err != nil {
+			return errors.Wrapf(err, "invalid date %q of date range on %s", value, r.Field)
+		}
+	}
+	return nil
+}
+
+func (r DateRange) format() string {
+	if stringutils.IsEmpty(r.Format) {
+		return DefaultDateFormat
+	}
+	return r.Format
+}
+
+func (r DateRange) rangeQuery(zone *time.Location) *elastic.RangeQuery {
+	if zone == nil {
+		zone = time.Local
+	}
+	rangeQuery := elastic.NewRangeQuery(r.Field).Format(r.format()).TimeZone(zone.String())
+	if stringutils.IsNotEmpty(r.Start) {
+		if r.ExcludeStart {
+			rangeQuery.Gt(r.Start)
+		} else {
+			rangeQuery.Gte(r.Start)
+		}
+	}
+	if stringutils.IsNotEmpty(r.End) {
+		if r.IncludeEnd {
+			rangeQuery.Lte(r.End)
+		} else {
+			rangeQuery.Lt(r.End)
+		}
+	}
+	return rangeQuery
+}
+
+// checkDate parses value as date math expression or date in format
+func checkDate(value, format string) error {
+	anchor, expr := value, ""
+	if strings.HasPrefix(value, "now") {
+		anchor, expr = "", strings.TrimPrefix(value, "now")
+	} else if i := strings.Index(value, "||"); i >= 0 {
+		anchor, expr = value[:i], value[i+2:]
+	}
+	if !dateMathExpr.MatchString(expr) {
+		return errors.Errorf("unsupported date math %q", expr)
+	}
+	if stringutils.IsEmpty(anchor) {
+		return nil
+	}
+	for _, f := range strings.Split(format, "||") {
+		ok, known := parseDate(anchor, strings.TrimSpace(f))
+		if ok || !known {
+			return nil
+		}
+	}
+	return errors.Errorf("date does not match format %q", format)
+}
+
+// parseDate reports whether value matches es date format, known is false if the format is not supported here
+// and the value has to be checked by es
+func parseDate(value, format string) (ok bool, known bool) {
+	switch format {
+	case "epoch_millis", "epoch_second":
+		for i, c := range value {
+			if (c < '0' || c > '9') && !(i == 0 && c == '-') {
+				return false, true
+			}
+		}
+		return value != "" && value != "-", true
+	case "strict_date_optional_time", "date_optional_time", "strict_date_optional_time_nanos":
+		for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05.999999999", time.RFC3339Nano} {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	layout, known := goLayout(format)
+	if !known {
+		return false, false
+	}
+	_, err := time.Parse(layout, value)
+	return err == nil, true
+}
+
+var dateLayoutTokens = map[string]string{
+	"yyyy": "2006",
+	"yy":   "06",
+	"MM":   "01",
+	"M":    "1",
+	"dd":   "02",
+	"d":    "2",
+	"HH":   "15",
+	"hh":   "03",
+	"mm":   "04",
+	"ss":   "05",
+	"SSS":  "000",
+	"a":    "PM",
+	"XXX":  "Z07:00",
+	"Z":    "-0700",
+}
+
+// goLayout converts simple java date patterns like yyyy-MM-dd HH:mm:ss to go time layout
+func goLayout(format string) (string, bool) {
+	var sb strings.Builder
+	runes := []rune(format)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		if c == '\'' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return "", false
+			}
+			sb.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+			continue
+		}
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			sb.WriteRune(c)
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && runes[j] == c {
+			j++
+		}
+		layout, ok := dateLayoutTokens[string(runes[i:j])]
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(layout)
+		i = j
+	}
+	return sb.String(), true
+}
+
+// dateRange returns the window of StartDate, EndDate and DateField, ok is false if neither StartDate nor EndDate is set.
+// StartDate and EndDate are ignored without DateField as they always have been
+func (p Paging) dateRange() (DateRange, bool) {
+	if stringutils.IsEmpty(p.DateField) || stringutils.IsEmpty(p.StartDate) && stringutils.IsEmpty(p.EndDate) {
+		return DateRange{}, false
+	}
+	return DateRange{
+		Field: p.DateField,
+		Start: p.StartDate,
+		End:   p.EndDate,
+	}, true
+}
+
+// pagingQuery builds the query of paging, date windows which cannot be parsed are returned as error
+// instead of being sent to es
+func pagingQuery(paging *Paging, zone *time.Location) (*elastic.BoolQuery, error) {
+	if r, ok := paging.dateRange(); ok {
+		if err := r.check(); err != nil {
+			return nil, errors.Wrap(err, "invalid startDate, endDate or dateField")
+		}
+	}
+	for i, r := range paging.DateRanges {
+		if err := r.check(); err != nil {
+			return nil, errors.Wrapf(err, "invalid dateRanges[%d]", i)
+		}
+	}
+	boolQuery := query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, paging.MinimumShouldMatch, zone)
+	for _, r := range paging.DateRanges {
+		boolQuery.Filter(r.rangeQuery(zone))
+	}
+	return boolQuery, nil
+}
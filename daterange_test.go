@@ -0,0 +1,138 @@
+package esutils
+
+import (
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_pagingQuery_dateRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		paging  *Paging
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "open-ended",
+			paging: &Paging{
+				DateField: "createAt",
+				StartDate: "2020-06-01",
+			},
+			want: `{"bool":{"filter":{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"2020-06-01","include_lower":true,"include_upper":true,"time_zone":"Asia/Shanghai","to":null}}}}}`,
+		},
+		{
+			name: "date math and multiple windows",
+			paging: &Paging{
+				DateField: "createAt",
+				StartDate: "now-7d/d",
+				EndDate:   "now",
+				DateRanges: []DateRange{
+					{
+						Field:        "acceptDate",
+						Start:        "2020/06/01",
+						End:          "2020/06/01||+1M/d",
+						ExcludeStart: true,
+						IncludeEnd:   true,
+						Format:       "yyyy/MM/dd",
+					},
+				},
+			},
+			want: `{"bool":{"filter":[{"range":{"createAt":{"format":"yyyy-MM-dd HH:mm:ss||yyyy-MM-dd||epoch_millis","from":"now-7d/d","include_lower":true,"include_upper":false,"time_zone":"Asia/Shanghai","to":"now"}}},{"range":{"acceptDate":{"format":"yyyy/MM/dd","from":"2020/06/01","include_lower":false,"include_upper":true,"time_zone":"Asia/Shanghai","to":"2020/06/01||+1M/d"}}}]}}`,
+		},
+		{
+			name: "date field only",
+			paging: &Paging{
+				DateField: "createAt",
+			},
+			want: `{"bool":{}}`,
+		},
+		{
+			name: "invalid date",
+			paging: &Paging{
+				DateField: "createAt",
+				StartDate: "2020-13-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid date math",
+			paging: &Paging{
+				DateRanges: []DateRange{
+					{
+						Field: "createAt",
+						End:   "now-7x",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing date field",
+			paging: &Paging{
+				EndDate: "2020-07-01",
+			},
+			want: `{"bool":{}}`,
+		},
+		{
+			name: "missing date field of date ranges",
+			paging: &Paging{
+				DateRanges: []DateRange{
+					{
+						End: "2020-07-01",
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pagingQuery(tt.paging, loc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pagingQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			src, err := got.Source()
+			if err != nil {
+				panic(err)
+			}
+			assert.JSONEq(t, tt.want, gabs.Wrap(src).String())
+		})
+	}
+}
+
+func TestPaging_String_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		paging Paging
+		want   string
+	}{
+		{
+			name: "invalid date",
+			paging: Paging{
+				DateField: "createAt",
+				StartDate: "2020-13-01",
+			},
+			want: `call pagingQuery() error: invalid startDate, endDate or dateField: invalid date "2020-13-01" of date range on createAt`,
+		},
+		{
+			name: "invalid zone",
+			paging: Paging{
+				Zone: "Mars/Olympus",
+			},
+			want: "call LoadLocation() error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NotPanics(t, func() {
+				assert.Contains(t, tt.paging.String(), tt.want)
+			})
+		})
+	}
+}
@@ -7,17 +7,23 @@ import (
 )
 
 // DeleteIndex removes the index
-func (es *Es) DeleteIndex(ctx context.Context) error {
+func (es *Es) DeleteIndex(ctx context.Context) (err error) {
+	ctx, op, err := es.begin(ctx, "DeleteIndex", &Call{Write: true})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
 	var (
-		err error
 		res *elastic.IndicesDeleteResponse
 	)
 	if res, err = es.client.DeleteIndex(es.esIndex).Do(ctx); err != nil {
 		if elastic.IsNotFound(err) {
+			es.InvalidateMappingCache()
 			return nil
 		}
 		return errors.Wrap(err, "call DeleteIndex() error")
 	}
+	es.InvalidateMappingCache()
 	if !res.Acknowledged {
 		return errors.New("failed to delete index" + es.esIndex)
 	}
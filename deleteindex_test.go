@@ -6,7 +6,7 @@ import (
 )
 
 func TestDeleteIndex(t *testing.T) {
-	es := setupSubTest("test_deleteindex")
+	es := setupSubTest(t)
 
 	tests := []struct {
 		name    string
@@ -27,7 +27,7 @@ func TestDeleteIndex(t *testing.T) {
 }
 
 func TestDeleteIndex1(t *testing.T) {
-	es := setupSubTest("test_deleteindex1")
+	es := setupSubTest(t)
 	es.SetIndex("notexistsindex")
 
 	tests := []struct {
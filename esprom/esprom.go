@@ -0,0 +1,124 @@
+// Package esprom exports metrics of esutils.Es to Prometheus
+package esprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+// Collector implements esutils.Metrics and prometheus.Collector, register it and pass it to esutils.WithMetrics
+type Collector struct {
+	latency        *prometheus.HistogramVec
+	errors         *prometheus.CounterVec
+	hits           *prometheus.CounterVec
+	bulkItems      *prometheus.CounterVec
+	scrollContexts *prometheus.GaugeVec
+}
+
+var _ esutils.Metrics = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// Option configures Collector
+type Option func(*options)
+
+type options struct {
+	namespace string
+	buckets   []float64
+}
+
+// WithNamespace sets namespace of metric names, default is esutils
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets sets buckets in seconds of the latency histogram, default is prometheus.DefBuckets
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// NewCollector creates a Collector
+func NewCollector(opts ...Option) *Collector {
+	o := &options{
+		namespace: "esutils",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Collector{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of esutils operations.",
+			Buckets:   o.buckets,
+		}, []string{"operation", "index"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "operation_errors_total",
+			Help:      "Failed esutils operations by error type, one of timeout, version_conflict, 4xx, 5xx and other.",
+		}, []string{"operation", "index", "type"}),
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "hits_total",
+			Help:      "Docs returned by esutils operations.",
+		}, []string{"operation", "index"}),
+		bulkItems: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "bulk_items_total",
+			Help:      "Items of bulk requests by result, one of succeeded and failed.",
+		}, []string{"operation", "index", "result"}),
+		scrollContexts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "scroll_contexts_open",
+			Help:      "Scroll contexts currently open.",
+		}, []string{"index"}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.latency.Describe(ch)
+	c.errors.Describe(ch)
+	c.hits.Describe(ch)
+	c.bulkItems.Describe(ch)
+	c.scrollContexts.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.latency.Collect(ch)
+	c.errors.Collect(ch)
+	c.hits.Collect(ch)
+	c.bulkItems.Collect(ch)
+	c.scrollContexts.Collect(ch)
+}
+
+// ObserveOperation implements esutils.Metrics
+func (c *Collector) ObserveOperation(op, index string, duration time.Duration, errType string) {
+	c.latency.WithLabelValues(op, index).Observe(duration.Seconds())
+	if errType != "" {
+		c.errors.WithLabelValues(op, index, errType).Inc()
+	}
+}
+
+// AddHits implements esutils.Metrics
+func (c *Collector) AddHits(op, index string, hits int) {
+	c.hits.WithLabelValues(op, index).Add(float64(hits))
+}
+
+// AddBulkItems implements esutils.Metrics
+func (c *Collector) AddBulkItems(op, index string, succeeded, failed int) {
+	c.bulkItems.WithLabelValues(op, index, "succeeded").Add(float64(succeeded))
+	c.bulkItems.WithLabelValues(op, index, "failed").Add(float64(failed))
+}
+
+// AddScrollContexts implements esutils.Metrics
+func (c *Collector) AddScrollContexts(index string, delta int) {
+	c.scrollContexts.WithLabelValues(index).Add(float64(delta))
+}
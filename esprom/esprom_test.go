@@ -0,0 +1,41 @@
+package esprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(WithNamespace("search"))
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	c.ObserveOperation("Page", "orders", 20*time.Millisecond, "")
+	c.ObserveOperation("SaveOrUpdate", "orders", 5*time.Millisecond, esutils.ErrVersionConflict)
+	c.AddHits("Page", "orders", 10)
+	c.AddBulkItems("BulkSaveOrUpdate", "orders", 98, 2)
+	c.AddScrollContexts("orders", 1)
+	c.AddScrollContexts("orders", 1)
+	c.AddScrollContexts("orders", -1)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(c, "search_operation_duration_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.errors.WithLabelValues("SaveOrUpdate", "orders", "version_conflict")))
+	assert.Equal(t, float64(10), testutil.ToFloat64(c.hits.WithLabelValues("Page", "orders")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(c.bulkItems.WithLabelValues("BulkSaveOrUpdate", "orders", "failed")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.scrollContexts.WithLabelValues("orders")))
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP search_bulk_items_total Items of bulk requests by result, one of succeeded and failed.
+# TYPE search_bulk_items_total counter
+search_bulk_items_total{index="orders",operation="BulkSaveOrUpdate",result="failed"} 2
+search_bulk_items_total{index="orders",operation="BulkSaveOrUpdate",result="succeeded"} 98
+`), "search_bulk_items_total")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package esutilstest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unionj-cloud/go-doudou/toolkit/copier"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+// AssertCount asserts that want docs of es match paging
+func AssertCount(t assert.TestingT, es esutils.EsClient, paging *esutils.Paging, want int64) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	got, err := es.Count(context.Background(), paging)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	return assert.Equal(t, want, got, "count of docs")
+}
+
+// AssertIDs asserts that ids of docs of es matching paging are want in any order.
+// All docs are listed if paging is nil or its Limit is 0
+func AssertIDs(t assert.TestingT, es esutils.EsClient, paging *esutils.Paging, want ...string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	got, ok := listIDs(t, es, paging, -1)
+	if !ok {
+		return false
+	}
+	return assert.ElementsMatch(t, want, got, "ids of docs")
+}
+
+// AssertSortedIDs asserts that ids of docs of es matching paging are want in order, e.g. to check Sortby.
+// The first 10000 docs are listed if paging is nil or its Limit is 0. Limit must not be negative or over 10000,
+// as es lists all docs by scrolling then, which returns docs in no particular order
+func AssertSortedIDs(t assert.TestingT, es esutils.EsClient, paging *esutils.Paging, want ...string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if paging != nil && (paging.Limit < 0 || paging.Limit > 10000) {
+		return assert.Fail(t, fmt.Sprintf("limit %d lists docs in no particular order, set limit between 0 and 10000", paging.Limit))
+	}
+	got, ok := listIDs(t, es, paging, 10000)
+	if !ok {
+		return false
+	}
+	if len(want) == 0 && len(got) == 0 {
+		return true
+	}
+	return assert.Equal(t, want, got, "ids of docs")
+}
+
+// listIDs lists ids of docs matching paging, limit is used if Limit of paging is 0
+func listIDs(t assert.TestingT, es esutils.EsClient, paging *esutils.Paging, limit int) ([]string, bool) {
+	if paging == nil {
+		paging = &esutils.Paging{}
+	}
+	p := *paging
+	if p.Limit == 0 {
+		p.Limit = limit
+	}
+	docs, err := es.List(context.Background(), &p, nil)
+	if !assert.NoError(t, err) {
+		return nil, false
+	}
+	var ids []string
+	for _, doc := range docs {
+		source, _ := doc.(map[string]interface{})
+		ids = append(ids, fmt.Sprintf("%v", source["_id"]))
+	}
+	return ids, true
+}
+
+// AssertDoc asserts that the doc of id has fields of want, other fields of the doc are ignored.
+// Values of want are compared in json form, e.g. int 1 equals float64 1 of the doc
+func AssertDoc(t assert.TestingT, es esutils.EsClient, id string, want map[string]interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	doc, err := es.GetByID(context.Background(), id)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	var expected map[string]interface{}
+	if err = copier.DeepCopy(want, &expected); !assert.NoError(t, err) {
+		return false
+	}
+	got := make(map[string]interface{})
+	for key := range expected {
+		if value, ok := doc[key]; ok {
+			got[key] = value
+		}
+	}
+	return assert.Equal(t, expected, got, "fields of doc %s", id)
+}
@@ -0,0 +1,190 @@
+// Package esutilstest helps testing code using esutils against a real es cluster: it starts an es container or
+// connects to an existing cluster, creates uniquely named indices deleted after each test, loads fixtures and
+// asserts query results. Server stands in for es in unit tests of requests and error handling without a cluster.
+//
+//	var cluster *esutilstest.Cluster
+//
+//	func TestMain(m *testing.M) {
+//		var err error
+//		if cluster, err = esutilstest.Start(context.Background()); err != nil {
+//			panic(err)
+//		}
+//		code := m.Run()
+//		cluster.Terminate(context.Background())
+//		os.Exit(code)
+//	}
+//
+//	func TestSearch(t *testing.T) {
+//		es := cluster.NewIndex(t, mapping)
+//		esutilstest.LoadFixtures(t, es, "testdata/docs.ndjson")
+//		esutilstest.AssertCount(t, es, &esutils.Paging{QueryConds: conds}, 2)
+//	}
+package esutilstest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+// EnvURL is the environment variable of the url of an existing es cluster, e.g. http://localhost:9200.
+// Start connects to the cluster instead of starting a container if it is set
+const EnvURL = "ESUTILS_TEST_URL"
+
+// DefaultImage is the docker image of the es container
+const DefaultImage = "docker.elastic.co/elasticsearch/elasticsearch:7.17.4"
+
+// Cluster is an es cluster for tests
+type Cluster struct {
+	// URL is the url of the cluster, e.g. http://localhost:49153
+	URL       string
+	container testcontainers.Container
+}
+
+// Option configures Start
+type Option func(*options)
+
+type options struct {
+	image  string
+	logger esutils.Logger
+}
+
+// WithImage sets docker image of the es container, default is DefaultImage
+func WithImage(image string) Option {
+	return func(o *options) {
+		o.image = image
+	}
+}
+
+// WithLogger sets logger of the container lifecycle
+func WithLogger(logger esutils.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+
+// Start starts a single node es container and waits for it to be green. If EnvURL is set, it returns the cluster of
+// EnvURL without starting a container
+func Start(ctx context.Context, opts ...Option) (*Cluster, error) {
+	o := options{
+		image:  DefaultImage,
+		logger: nopLogger{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if url := os.Getenv(EnvURL); stringutils.IsNotEmpty(url) {
+		o.logger.Infof("use Elasticsearch cluster %s of %s", url, EnvURL)
+		return &Cluster{
+			URL: strings.TrimRight(url, "/"),
+		}, nil
+	}
+	o.logger.Infof("setup Elasticsearch container %s", o.image)
+	req := testcontainers.ContainerRequest{
+		Image:        o.image,
+		ExposedPorts: []string{"9200/tcp"},
+		Env: map[string]string{
+			"node.name":             "single-node",
+			"bootstrap.memory_lock": "true",
+			"cluster.name":          "testcontainers-go",
+			"discovery.type":        "single-node",
+			"ES_JAVA_OPTS":          "-Xms1g -Xmx1g",
+		},
+		WaitingFor: wait.ForLog("Cluster health status changed from [YELLOW] to [GREEN]"),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "call GenericContainer() error")
+	}
+	cluster := &Cluster{
+		container: container,
+	}
+	host, err := container.Host(ctx)
+	if err != nil {
+		cluster.Terminate(ctx)
+		return nil, errors.Wrap(err, "call Host() error")
+	}
+	port, err := container.MappedPort(ctx, "9200/tcp")
+	if err != nil {
+		cluster.Terminate(ctx)
+		return nil, errors.Wrap(err, "call MappedPort() error")
+	}
+	cluster.URL = fmt.Sprintf("http://%s:%d", host, port.Int())
+	o.logger.Infof("Elasticsearch container is ready at %s", cluster.URL)
+	return cluster, nil
+}
+
+// Terminate removes the es container, the cluster of EnvURL is left running
+func (c *Cluster) Terminate(ctx context.Context) error {
+	if c.container == nil {
+		return nil
+	}
+	if err := c.container.Terminate(ctx); err != nil {
+		return errors.Wrap(err, "call Terminate() error")
+	}
+	return nil
+}
+
+// NewEs creates an Es of index on the cluster, opts are applied after the url of the cluster
+func (c *Cluster) NewEs(index string, opts ...esutils.EsOption) *esutils.Es {
+	return esutils.NewEs(index, append([]esutils.EsOption{esutils.WithUrls([]string{c.URL})}, opts...)...)
+}
+
+// NewIndex creates an index named by IndexName with mapping, or without mapping if it is empty,
+// and returns its Es. The index is deleted when the test and its subtests complete
+func (c *Cluster) NewIndex(t testing.TB, mapping string, opts ...esutils.EsOption) *esutils.Es {
+	t.Helper()
+	es := c.NewEs(IndexName(t), opts...)
+	var err error
+	if stringutils.IsEmpty(mapping) {
+		_, err = es.NewIndexOnly(context.Background())
+	} else {
+		_, err = es.NewIndex(context.Background(), mapping)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := es.DeleteIndex(context.Background()); err != nil {
+			t.Errorf("delete index %s: %v", es.GetIndex(), err)
+		}
+	})
+	return es
+}
+
+var (
+	indexSeq     int64
+	invalidIndex = regexp.MustCompile(`[^a-z0-9_.-]+`)
+)
+
+// maxIndexName leaves room for the suffix of IndexName within 255 bytes limit of es
+const maxIndexName = 200
+
+// IndexName returns an index name derived from the test name which is unique among tests of all test processes,
+// e.g. test_testsearch_sub_4242_1 for TestSearch/sub
+func IndexName(t testing.TB) string {
+	name := invalidIndex.ReplaceAllString(strings.ToLower(t.Name()), "_")
+	if len(name) > maxIndexName {
+		name = name[:maxIndexName]
+	}
+	return fmt.Sprintf("test_%s_%d_%d", name, os.Getpid(), atomic.AddInt64(&indexSeq, 1))
+}
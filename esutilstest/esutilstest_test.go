@@ -0,0 +1,184 @@
+package esutilstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+func TestStart_envURL(t *testing.T) {
+	os.Setenv(EnvURL, "http://localhost:9200/")
+	defer os.Unsetenv(EnvURL)
+	cluster, err := Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "http://localhost:9200", cluster.URL)
+	assert.NoError(t, cluster.Terminate(context.Background()))
+}
+
+func TestIndexName(t *testing.T) {
+	t.Run("Sub Test/with:chars", func(t *testing.T) {
+		name := IndexName(t)
+		assert.Regexp(t, regexp.MustCompile(`^test_testindexname_sub_test_with_chars_\d+_\d+$`), name)
+		assert.NotEqual(t, name, IndexName(t))
+	})
+}
+
+func TestReadFixtures(t *testing.T) {
+	docs, err := ReadFixtures("testdata/docs.json", "testdata/docs.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []interface{}
+	for _, doc := range docs {
+		ids = append(ids, doc.(map[string]interface{})["id"])
+	}
+	assert.Equal(t, []interface{}{"9seTXHoBNx091WJ2QCh5", "9seTXHoBNx091WJ2QCh6", "9seTXHoBNx091WJ2QCh7", json.Number("12345678901234567890")}, ids)
+
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "doc.json",
+			content: `{"id": "1"}`,
+			want:    1,
+		},
+		{
+			name:    "scalar.json",
+			content: `1`,
+			wantErr: true,
+		},
+		{
+			name:    "docs.jsonl",
+			content: "{\"create\":{}}\n{\"a\":1}\n\n{\"b\":2}\n",
+			want:    2,
+		},
+		{
+			name:    "broken.ndjson",
+			content: "{\"a\":1}\n{\"b\":",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(p, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			docs, err := ReadFixtures(p)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFixtures() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Len(t, docs, tt.want)
+		})
+	}
+	_, err = ReadFixtures("testdata/missing.json")
+	assert.Error(t, err)
+}
+
+// mockT records failures of assertions
+type mockT struct {
+	failures []string
+}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.failures = append(m.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssert(t *testing.T) {
+	es := esutils.NewFakeEs(IndexName(t))
+	docs := LoadFixtures(t, es, "testdata/docs.json", "testdata/docs.ndjson")
+	assert.Len(t, docs, 4)
+
+	culture := &esutils.Paging{
+		QueryConds: []esutils.QueryCond{
+			{
+				Pair: map[string][]interface{}{
+					"type.keyword": {"culture"},
+				},
+				QueryLogic: esutils.MUST,
+				QueryType:  esutils.TERMS,
+			},
+		},
+		Sortby: []esutils.Sort{
+			{
+				Field:     "createAt",
+				Ascending: false,
+			},
+		},
+	}
+	AssertCount(t, es, nil, 4)
+	AssertCount(t, es, culture, 2)
+	AssertIDs(t, es, culture, "9seTXHoBNx091WJ2QCh7", "12345678901234567890")
+	AssertSortedIDs(t, es, culture, "12345678901234567890", "9seTXHoBNx091WJ2QCh7")
+	AssertDoc(t, es, "9seTXHoBNx091WJ2QCh6", map[string]interface{}{
+		"type": "sport",
+	})
+
+	mt := &mockT{}
+	assert.False(t, AssertCount(mt, es, culture, 1))
+	assert.False(t, AssertIDs(mt, es, culture, "9seTXHoBNx091WJ2QCh7"))
+	assert.False(t, AssertSortedIDs(mt, es, culture, "9seTXHoBNx091WJ2QCh7", "12345678901234567890"))
+	assert.False(t, AssertDoc(mt, es, "9seTXHoBNx091WJ2QCh6", map[string]interface{}{"type": "culture"}))
+	assert.False(t, AssertDoc(mt, es, "missing", nil))
+	assert.Len(t, mt.failures, 5)
+}
+
+func TestAssertSortedIDs_server(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/orders/_doc/_search", SearchResponse(2,
+		map[string]interface{}{"_id": "2", "title": "pen"},
+		map[string]interface{}{"_id": "1", "title": "book"},
+	))
+	es := server.NewEs("orders")
+	paging := &esutils.Paging{
+		Sortby: []esutils.Sort{
+			{
+				Field:     "title.keyword",
+				Ascending: false,
+			},
+		},
+	}
+	AssertSortedIDs(t, es, paging, "2", "1")
+	if assert.Len(t, server.Requests(), 1) {
+		var body struct {
+			Size int                      `json:"size"`
+			Sort []map[string]interface{} `json:"sort"`
+		}
+		if err := server.Requests()[0].Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 10000, body.Size)
+		assert.Equal(t, []map[string]interface{}{{"title.keyword": map[string]interface{}{"order": "desc"}}}, body.Sort)
+	}
+
+	server.Reset()
+	mt := &mockT{}
+	for _, limit := range []int{-1, 10001} {
+		p := *paging
+		p.Limit = limit
+		assert.False(t, AssertSortedIDs(mt, es, &p, "2", "1"))
+	}
+	assert.Len(t, mt.failures, 2)
+	assert.Empty(t, server.Requests())
+}
@@ -0,0 +1,134 @@
+package esutilstest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+// ReadFixtures reads docs of files. Files with .ndjson or .jsonl extension have a doc per line, other files have
+// a doc or an array of docs. Lines of ndjson files may also be bulk requests of es like dumps of elasticdump:
+// _id of an index or create action is set to id field of the doc in the next line unless it has one,
+// because esutils takes ids from id field. Numbers are decoded as json.Number to keep big ids intact
+func ReadFixtures(paths ...string) ([]interface{}, error) {
+	var docs []interface{}
+	for _, p := range paths {
+		var (
+			fileDocs []interface{}
+			err      error
+		)
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".ndjson", ".jsonl":
+			fileDocs, err = readNDJSON(p)
+		default:
+			fileDocs, err = readJSON(p)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "read fixture %s error", p)
+		}
+		docs = append(docs, fileDocs...)
+	}
+	return docs, nil
+}
+
+func readJSON(p string) ([]interface{}, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Open() error")
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "call Decode() error")
+	}
+	switch data := v.(type) {
+	case []interface{}:
+		return data, nil
+	case map[string]interface{}:
+		return []interface{}{data}, nil
+	}
+	return nil, errors.New("fixture must be a json object or an array of json objects")
+}
+
+var bulkActions = map[string]bool{
+	"index":  true,
+	"create": true,
+	"update": false,
+	"delete": false,
+}
+
+func readNDJSON(p string) ([]interface{}, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Open() error")
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	var (
+		docs []interface{}
+		// id is _id of the last bulk action, nil if the last value is not a bulk action
+		id interface{}
+	)
+	for {
+		var doc map[string]interface{}
+		if err = dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "call Decode() error")
+		}
+		if id == nil && len(doc) == 1 {
+			if action, ok := bulkAction(doc); ok {
+				id = action["_id"]
+				if id == nil {
+					id = ""
+				}
+				continue
+			}
+		}
+		if id != nil {
+			if _, ok := doc["id"]; !ok && id != "" {
+				doc["id"] = id
+			}
+			id = nil
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// bulkAction returns metadata of index or create action line
+func bulkAction(line map[string]interface{}) (map[string]interface{}, bool) {
+	for name, value := range line {
+		metadata, ok := value.(map[string]interface{})
+		if supported, known := bulkActions[name]; ok && known && supported {
+			return metadata, true
+		}
+	}
+	return nil, false
+}
+
+// LoadFixtures saves docs of files read by ReadFixtures to es and returns them, the test fails on errors
+func LoadFixtures(t testing.TB, es esutils.EsClient, paths ...string) []interface{} {
+	t.Helper()
+	docs, err := ReadFixtures(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) == 0 {
+		return docs
+	}
+	if err = es.BulkSaveOrUpdate(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
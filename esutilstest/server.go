@@ -0,0 +1,320 @@
+package esutilstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+// Server is a stand-in es http endpoint for unit tests without es. It records every request and replies responses
+// scripted by Handle and HandleFunc, unscripted requests are replied 200 with {}
+//
+//	server := esutilstest.NewServer()
+//	defer server.Close()
+//	server.Handle(http.MethodPost, "/orders/_doc/_bulk", esutilstest.BulkResponse("", "mapper_parsing_exception"))
+//	es := server.NewEs("orders")
+//	err := es.BulkSaveOrUpdate(ctx, docs)
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []Request
+	routes   []*route
+}
+
+// Request is a request received by Server
+type Request struct {
+	Method string
+	// Path is the url path, e.g. /orders/_doc/_search
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals json body of the request into v
+func (r Request) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Response is a scripted response of Server
+type Response struct {
+	// Status is http status code, default is 200
+	Status int
+	// Body is written as is if it is string or []byte, otherwise it is marshalled as json
+	Body interface{}
+	// Delay delays the response unless the request is canceled, e.g. to test cancellation and timeouts
+	Delay time.Duration
+}
+
+type route struct {
+	method  string
+	pattern string
+	handler func(r Request) Response
+}
+
+// NewServer starts a Server, Close it when the test is done
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle replies requests of method and path matching pattern of path.Match with responses in order,
+// the last response is repeated, e.g. /orders/*/_search. Note that * doesn't match /.
+// Empty method or pattern matches any. Routes added later take precedence
+func (s *Server) Handle(method, pattern string, responses ...Response) {
+	if len(responses) == 0 {
+		responses = []Response{{}}
+	}
+	var (
+		mu sync.Mutex
+		i  int
+	)
+	s.HandleFunc(method, pattern, func(r Request) Response {
+		mu.Lock()
+		defer mu.Unlock()
+		resp := responses[i]
+		if i < len(responses)-1 {
+			i++
+		}
+		return resp
+	})
+}
+
+// HandleFunc replies requests of method and path matching pattern of path.Match with handler.
+// Empty method or pattern matches any. Routes added later take precedence
+func (s *Server) HandleFunc(method, pattern string, handler func(r Request) Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, &route{
+		method:  method,
+		pattern: pattern,
+		handler: handler,
+	})
+}
+
+// Requests returns received requests in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Paths returns method and path of received requests in order, e.g. POST /orders/_doc/_search
+func (s *Server) Paths() []string {
+	var paths []string
+	for _, r := range s.Requests() {
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	return paths
+}
+
+// Reset forgets received requests, scripted routes are kept
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// NewClient creates an elastic client of the server without sniffing and healthchecks, opts are applied after them
+func (s *Server) NewClient(opts ...elastic.ClientOptionFunc) (*elastic.Client, error) {
+	return elastic.NewSimpleClient(append([]elastic.ClientOptionFunc{elastic.SetURL(s.URL)}, opts...)...)
+}
+
+// NewEs creates an Es of index using the client of NewClient by esutils.WithClient
+func (s *Server) NewEs(index string, opts ...esutils.EsOption) *esutils.Es {
+	client, err := s.NewClient()
+	if err != nil {
+		panic(fmt.Errorf("NewClient() error: %+v\n", err))
+	}
+	return esutils.NewEs(index, append([]esutils.EsOption{esutils.WithClient(client)}, opts...)...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var handler func(r Request) Response
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		if s.routes[i].match(req) {
+			handler = s.routes[i].handler
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	resp := Response{
+		Body: "{}",
+	}
+	if handler != nil {
+		resp = handler(req)
+	}
+	if resp.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(resp.Delay):
+		}
+	}
+	var data []byte
+	switch b := resp.Body.(type) {
+	case nil:
+		data = []byte("{}")
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (rt *route) match(r Request) bool {
+	if rt.method != "" && rt.method != r.Method {
+		return false
+	}
+	if rt.pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(rt.pattern, r.Path)
+	return matched
+}
+
+// ErrorResponse returns an error response of es with status, error type and reason
+func ErrorResponse(status int, errType, reason string) Response {
+	return Response{
+		Status: status,
+		Body: map[string]interface{}{
+			"error": map[string]interface{}{
+				"root_cause": []interface{}{
+					map[string]interface{}{
+						"type":   errType,
+						"reason": reason,
+					},
+				},
+				"type":   errType,
+				"reason": reason,
+			},
+			"status": status,
+		},
+	}
+}
+
+// NotFound returns the 404 response of es for a missing index
+func NotFound(index string) Response {
+	resp := ErrorResponse(http.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", index))
+	resp.Body.(map[string]interface{})["error"].(map[string]interface{})["index"] = index
+	return resp
+}
+
+// TooManyRequests returns the 429 response of es rejecting requests when queues are full
+func TooManyRequests() Response {
+	return ErrorResponse(http.StatusTooManyRequests, "es_rejected_execution_exception", "rejected execution of coordinating operation")
+}
+
+// BulkResponse returns response of a bulk request of index actions, an item per reason in order.
+// The item succeeds if the reason is empty, otherwise it fails with the reason like a partial failure of es
+func BulkResponse(reasons ...string) Response {
+	var (
+		items  []interface{}
+		failed bool
+	)
+	for i, reason := range reasons {
+		item := map[string]interface{}{
+			"_id":     fmt.Sprint(i + 1),
+			"_type":   "_doc",
+			"result":  "created",
+			"status":  http.StatusCreated,
+			"_shards": map[string]interface{}{"total": 1, "successful": 1, "failed": 0},
+		}
+		if reason != "" {
+			failed = true
+			delete(item, "result")
+			delete(item, "_shards")
+			item["status"] = http.StatusBadRequest
+			item["error"] = map[string]interface{}{
+				"type":   "mapper_parsing_exception",
+				"reason": reason,
+			}
+		}
+		items = append(items, map[string]interface{}{"index": item})
+	}
+	return Response{
+		Body: map[string]interface{}{
+			"took":   1,
+			"errors": failed,
+			"items":  items,
+		},
+	}
+}
+
+// SearchResponse returns response of a search request with total hits and docs of the page,
+// _id of docs is the id of hits and other fields are the source
+func SearchResponse(total int, docs ...map[string]interface{}) Response {
+	return Response{
+		Body: searchBody(total, docs),
+	}
+}
+
+// ScrollResponse returns a page of a scroll with scrollID, total hits and docs of the page like SearchResponse.
+// Script the last page without docs to end the scroll
+func ScrollResponse(scrollID string, total int, docs ...map[string]interface{}) Response {
+	body := searchBody(total, docs)
+	body["_scroll_id"] = scrollID
+	return Response{
+		Body: body,
+	}
+}
+
+func searchBody(total int, docs []map[string]interface{}) map[string]interface{} {
+	hits := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		source := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			if k != "_id" {
+				source[k] = v
+			}
+		}
+		hits = append(hits, map[string]interface{}{
+			"_id":     doc["_id"],
+			"_type":   "_doc",
+			"_score":  1,
+			"_source": source,
+		})
+	}
+	return map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"hits": map[string]interface{}{
+			"total": map[string]interface{}{"value": total, "relation": "eq"},
+			"hits":  hits,
+		},
+	}
+}
@@ -0,0 +1,185 @@
+package esutilstest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+)
+
+type order struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+func TestServer_getId(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.HandleFunc(http.MethodPut, "/orders/_doc/*", func(r Request) Response {
+		return Response{
+			Status: http.StatusCreated,
+			Body:   map[string]interface{}{"_id": r.Path[strings.LastIndex(r.Path, "/")+1:], "result": "created"},
+		}
+	})
+	es := server.NewEs("orders")
+
+	tests := []struct {
+		name    string
+		doc     interface{}
+		want    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name: "struct",
+			doc:  order{Id: 42, Title: "book"},
+			want: "42",
+			path: "PUT /orders/_doc/42",
+		},
+		{
+			name: "map",
+			doc:  map[string]interface{}{"id": "a1", "title": "pen"},
+			want: "a1",
+			path: "PUT /orders/_doc/a1",
+		},
+		{
+			name: "without id",
+			doc:  map[string]interface{}{"title": "pen"},
+			path: "POST /orders/_doc/",
+		},
+		{
+			name:    "slice",
+			doc:     []string{"pen"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Reset()
+			id, err := es.SaveOrUpdate(context.Background(), tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SaveOrUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Empty(t, server.Requests())
+				return
+			}
+			assert.Equal(t, tt.want, id)
+			assert.Equal(t, tt.path, server.Paths()[0])
+		})
+	}
+}
+
+func TestServer_bulk(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/orders/_doc/_bulk",
+		BulkResponse("", ""),
+		BulkResponse("", "failed to parse field [title]"),
+		TooManyRequests(),
+	)
+	es := server.NewEs("orders")
+	docs := []interface{}{
+		order{Id: 1, Title: "book"},
+		map[string]interface{}{"id": 2, "title": "pen"},
+	}
+
+	err := es.BulkSaveOrUpdate(context.Background(), docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	assert.Equal(t, []string{"POST /orders/_doc/_bulk", "POST /orders/_flush", "POST /orders/_refresh"}, server.Paths())
+	lines := strings.Split(strings.TrimSpace(string(requests[0].Body)), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.JSONEq(t, `{"index":{"_id":"1","_index":"orders","_type":"_doc"}}`, lines[0])
+		assert.JSONEq(t, `{"id":1,"title":"book"}`, lines[1])
+		assert.JSONEq(t, `{"index":{"_id":"2","_index":"orders","_type":"_doc"}}`, lines[2])
+		assert.JSONEq(t, `{"id":2,"title":"pen"}`, lines[3])
+	}
+
+	err = es.BulkSaveOrUpdate(context.Background(), docs)
+	assert.EqualError(t, err, "failed to parse field [title]")
+
+	err = es.BulkSaveOrUpdate(context.Background(), docs)
+	assert.True(t, elastic.IsStatusCode(errors.Cause(err), http.StatusTooManyRequests))
+	assert.Equal(t, esutils.ErrClient, esutils.ErrorType(err))
+
+	server.Reset()
+	err = es.BulkSaveOrUpdate(context.Background(), []interface{}{"pen"})
+	assert.Error(t, err)
+	assert.Empty(t, server.Requests())
+}
+
+func TestServer_notFound(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle("", "/orders/*/*", NotFound("orders"))
+	es := server.NewEs("orders")
+
+	_, err := es.GetByID(context.Background(), "1")
+	assert.True(t, elastic.IsNotFound(errors.Cause(err)))
+	_, err = es.Count(context.Background(), nil)
+	assert.True(t, elastic.IsNotFound(errors.Cause(err)))
+	assert.Equal(t, []string{"GET /orders/_doc/1", "POST /orders/_refresh", "POST /orders/_flush", "POST /orders/_doc/_count"}, server.Paths())
+}
+
+func TestServer_scroll(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/orders/_doc/_search", ScrollResponse("s1", 3,
+		map[string]interface{}{"_id": "1", "title": "book"},
+		map[string]interface{}{"_id": "2", "title": "pen"},
+	))
+	server.Handle(http.MethodPost, "/_search/scroll",
+		ScrollResponse("s1", 3, map[string]interface{}{"_id": "3", "title": "ink"}),
+		ScrollResponse("s1", 3),
+	)
+	es := server.NewEs("orders")
+
+	docs, err := es.List(context.Background(), &esutils.Paging{Limit: -1, ScrollSize: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []interface{}{
+		map[string]interface{}{"_id": "1", "title": "book"},
+		map[string]interface{}{"_id": "2", "title": "pen"},
+		map[string]interface{}{"_id": "3", "title": "ink"},
+	}, docs)
+	assert.Equal(t, []string{"POST /orders/_doc/_search", "POST /_search/scroll", "POST /_search/scroll", "DELETE /_search/scroll"}, server.Paths())
+	requests := server.Requests()
+	assert.Equal(t, "2", requests[0].Query.Get("size"))
+	var clear map[string]interface{}
+	if err = requests[3].Decode(&clear); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{"s1"}, clear["scroll_id"])
+}
+
+func TestServer_scrollCancel(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/orders/_doc/_search", ScrollResponse("s1", 2,
+		map[string]interface{}{"_id": "1", "title": "book"},
+	))
+	next := ScrollResponse("s1", 2, map[string]interface{}{"_id": "2", "title": "pen"})
+	next.Delay = time.Second
+	server.Handle(http.MethodPost, "/_search/scroll", next)
+	es := server.NewEs("orders")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := es.List(ctx, &esutils.Paging{Limit: -1}, nil)
+	assert.Equal(t, esutils.ErrTimeout, esutils.ErrorType(err))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	// the scroll is cleared though the request is canceled
+	assert.Equal(t, []string{"POST /orders/_doc/_search", "POST /_search/scroll", "DELETE /_search/scroll"}, server.Paths())
+}
@@ -0,0 +1,8 @@
+package esutils
+
+import "testing"
+
+// TestCluster is the es cluster internal tests run against, TestMain of esutils_test starts it
+var TestCluster interface {
+	NewIndex(t testing.TB, mapping string, opts ...EsOption) *Es
+}
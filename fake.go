@@ -0,0 +1,549 @@
+package esutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/copier"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// FakeEs is an in-memory EsClient for unit tests without es. It supports all QueryCond query types, date ranges,
+// Sortby, Skip, Limit, SearchAfter, Includes, Excludes, and terms and simple metric aggregations of Stat.
+// Text is split into lowercase words instead of being analyzed by es, term level queries like TERMS compare
+// raw values like keyword fields, and scores are not computed, so docs are returned in the order they were first saved
+// unless Sortby is set. Like es, List and Page with Limit below 0 or over 10000 fetch all docs ignoring Sortby, Skip and
+// SearchAfter, and return them ordered by _id, and Collapse is rejected. Relevance, Collapse, Profile and InnerHits
+// are ignored otherwise, and searching a point in time by PitID fails as FakeEs can't open one.
+// Like es, the index is created by NewIndex, NewIndexOnly or the first saved doc, and reading a missing index fails
+type FakeEs struct {
+	esIndex string
+	mu      sync.RWMutex
+	indices map[string]*fakeIndex
+	seq     int64
+}
+
+type fakeIndex struct {
+	docs       map[string]*fakeDoc
+	properties map[string]interface{}
+}
+
+type fakeDoc struct {
+	id string
+	// seq is the order of the doc being saved for the first time
+	seq    int64
+	raw    json.RawMessage
+	source map[string]interface{}
+}
+
+// fakeHit is a doc matching the query with its sort values
+type fakeHit struct {
+	*fakeDoc
+	sort     []interface{}
+	distance *float64
+}
+
+// NewFakeEs creates a FakeEs of index
+func NewFakeEs(index string) *FakeEs {
+	return &FakeEs{
+		esIndex: index,
+		indices: make(map[string]*fakeIndex),
+	}
+}
+
+// GetIndex returns index of the FakeEs
+func (f *FakeEs) GetIndex() string {
+	return f.esIndex
+}
+
+// SaveOrUpdate saves or updates doc
+func (f *FakeEs) SaveOrUpdate(ctx context.Context, doc interface{}) (string, error) {
+	id, err := getId(doc)
+	if err != nil {
+		return "", errors.Wrap(err, "method SaveOrUpdate() error")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id, err = f.save(id, doc); err != nil {
+		return "", errors.Wrap(err, "call Index() error")
+	}
+	return id, nil
+}
+
+// BulkSaveOrUpdate save or update docs in bulk
+func (f *FakeEs) BulkSaveOrUpdate(ctx context.Context, docs []interface{}) error {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		id, err := getId(doc)
+		if err != nil {
+			return errors.Wrap(err, "method BulkSaveOrUpdate() error")
+		}
+		ids = append(ids, id)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, doc := range docs {
+		if _, err := f.save(ids[i], doc); err != nil {
+			return errors.Wrap(err, "call Bulk() error")
+		}
+	}
+	return nil
+}
+
+// save stores doc with id in the index, a random id is generated if id is empty
+func (f *FakeEs) save(id string, doc interface{}) (string, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return "", errors.Wrap(err, "call Marshal() error")
+	}
+	var source map[string]interface{}
+	if err = json.Unmarshal(raw, &source); err != nil || source == nil {
+		return "", errors.New("doc must be a json object")
+	}
+	idx := f.index(true)
+	if stringutils.IsEmpty(id) {
+		for id = newFakeID(); idx.docs[id] != nil; id = newFakeID() {
+		}
+	}
+	if old, ok := idx.docs[id]; ok {
+		old.raw, old.source = raw, source
+		return id, nil
+	}
+	f.seq++
+	idx.docs[id] = &fakeDoc{
+		id:     id,
+		seq:    f.seq,
+		raw:    raw,
+		source: source,
+	}
+	return id, nil
+}
+
+// index returns the index of f, it is created if missing and create is true
+func (f *FakeEs) index(create bool) *fakeIndex {
+	idx, ok := f.indices[f.esIndex]
+	if !ok && create {
+		idx = newFakeIndex(nil)
+		f.indices[f.esIndex] = idx
+	}
+	return idx
+}
+
+func newFakeIndex(properties map[string]interface{}) *fakeIndex {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	return &fakeIndex{
+		docs:       make(map[string]*fakeDoc),
+		properties: properties,
+	}
+}
+
+const fakeIDChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// newFakeID returns a random id like the ones generated by es
+func newFakeID() string {
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = fakeIDChars[rand.Intn(len(fakeIDChars))]
+	}
+	return string(b)
+}
+
+// fakeNotFound returns the error returned by es for missing index or doc
+func fakeNotFound(errType, reason, index string) error {
+	return &elastic.Error{
+		Status: http.StatusNotFound,
+		Details: &elastic.ErrorDetails{
+			Type:   errType,
+			Reason: reason,
+			Index:  index,
+		},
+	}
+}
+
+func fakeIndexNotFound(index string) error {
+	return fakeNotFound("index_not_found_exception", fmt.Sprintf("no such index [%s]", index), index)
+}
+
+// GetByID gets a doc by id
+func (f *FakeEs) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	idx := f.index(false)
+	if idx == nil {
+		return nil, errors.Wrap(fakeIndexNotFound(f.esIndex), "call Get() error")
+	}
+	doc, ok := idx.docs[id]
+	if !ok {
+		return nil, errors.Wrap(fakeNotFound("not_found", fmt.Sprintf("doc [%s] not found", id), f.esIndex), "call Get() error")
+	}
+	return fakeHit{fakeDoc: doc}.doc(nil), nil
+}
+
+// BulkDelete delete es docs specified by ids in bulk
+func (f *FakeEs) BulkDelete(ctx context.Context, ids []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	idx := f.index(true)
+	for _, id := range ids {
+		delete(idx.docs, id)
+	}
+	return nil
+}
+
+// List fetch docs by paging
+func (f *FakeEs) List(ctx context.Context, paging *Paging, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
+	if paging == nil {
+		paging = &Paging{
+			Limit:      -1,
+			ScrollSize: 1000,
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	all := paging.Limit < 0 || paging.Limit > 10000
+	if all && paging.Collapse != nil {
+		return nil, errors.New("collapse is not supported when fetching all docs, set limit between 0 and 10000")
+	}
+	if all {
+		// like the scroll of Es, Sortby, Skip and SearchAfter are ignored
+		p := *paging
+		p.Sortby, p.SearchAfter = nil, nil
+		paging = &p
+	}
+	hits, err := f.search(paging)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Search() error")
+	}
+	if all {
+		// the order of the scroll is unspecified, sort by _id so that tests are deterministic
+		sort.Slice(hits, func(i, j int) bool {
+			return hits[i].id < hits[j].id
+		})
+	} else {
+		hits = fakePage(hits, paging)
+	}
+	var rets []interface{}
+	for _, hit := range hits {
+		if callback == nil {
+			rets = append(rets, hit.doc(paging))
+			continue
+		}
+		ret, err := callback(hit.filteredRaw(paging))
+		if err != nil {
+			return nil, errors.Wrap(err, "call callback() error")
+		}
+		rets = append(rets, ret)
+	}
+	return rets, nil
+}
+
+// Page fetch pagination result
+func (f *FakeEs) Page(ctx context.Context, paging *Paging) (PageResult, error) {
+	var pr PageResult
+	if paging == nil {
+		paging = &Paging{
+			Limit: -1,
+		}
+	}
+	if paging.Limit < 0 || paging.Limit > 10000 {
+		docs, err := f.List(ctx, paging, nil)
+		if err != nil {
+			return pr, errors.Wrap(err, "call List() error")
+		}
+		pr.Total = len(docs)
+		pr.Docs = docs
+		return pr, nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	hits, err := f.search(paging)
+	if err != nil {
+		return pr, errors.Wrap(err, "call Search() error")
+	}
+	pr.Total = len(hits)
+	hits = fakePage(hits, paging)
+	for _, hit := range hits {
+		pr.Docs = append(pr.Docs, hit.doc(paging))
+	}
+	if n := len(hits); n > 0 {
+		pr.SearchAfter = hits[n-1].sort
+	}
+	pr.PageSize = paging.Limit
+	if len(paging.SearchAfter) > 0 {
+		pr.HasNextPage = paging.Limit > 0 && len(hits) == paging.Limit
+		return pr, nil
+	}
+	if paging.Limit > 0 {
+		pr.Page = paging.Skip/paging.Limit + 1
+	}
+	var totalPage int
+	if pr.PageSize > 0 {
+		totalPage = (pr.Total + pr.PageSize - 1) / pr.PageSize
+	}
+	pr.HasNextPage = pr.Page < totalPage
+	return pr, nil
+}
+
+// Count counts docs by paging
+func (f *FakeEs) Count(ctx context.Context, paging *Paging) (int64, error) {
+	if paging == nil {
+		paging = &Paging{}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	hits, err := f.search(paging)
+	if err != nil {
+		return 0, errors.Wrap(err, "call Count() error")
+	}
+	return int64(len(hits)), nil
+}
+
+// Stat aggr only accept map[string]interface{} or elastic.Aggregation, aggregations other than terms, value_count,
+// cardinality, sum, avg, min and max are not supported
+func (f *FakeEs) Stat(ctx context.Context, paging *Paging, aggr interface{}) (map[string]interface{}, error) {
+	if paging == nil {
+		paging = &Paging{}
+	}
+	var aggs map[string]interface{}
+	switch raw := aggr.(type) {
+	case map[string]interface{}:
+		if err := copier.DeepCopy(raw, &aggs); err != nil {
+			return nil, errors.Wrap(err, "call DeepCopy() error")
+		}
+	case elastic.Aggregation:
+		src, err := raw.Source()
+		if err != nil {
+			return nil, errors.Wrap(err, "call Source() error")
+		}
+		if err = copier.DeepCopy(map[string]interface{}{"volume": src}, &aggs); err != nil {
+			return nil, errors.Wrap(err, "call DeepCopy() error")
+		}
+	default:
+		return nil, nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	hits, err := f.search(paging)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Search() error")
+	}
+	result, err := fakeAggs(aggs, hits)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Search() error")
+	}
+	var ret map[string]interface{}
+	copier.DeepCopy(result, &ret)
+	return ret, nil
+}
+
+// NewIndex creates a new index
+func (f *FakeEs) NewIndex(ctx context.Context, mapping string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.index(false) != nil {
+		return true, nil
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(mapping), &body); err != nil {
+		return false, errors.Wrap(err, "call CreateIndex() error")
+	}
+	f.indices[f.esIndex] = newFakeIndex(fakeProperties(body["mappings"]))
+	return false, nil
+}
+
+// NewIndexOnly creates a new index without settings and mappings
+func (f *FakeEs) NewIndexOnly(ctx context.Context) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.index(false) != nil {
+		return true, nil
+	}
+	f.index(true)
+	return false, nil
+}
+
+// DeleteIndex removes the index
+func (f *FakeEs) DeleteIndex(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.indices, f.esIndex)
+	return nil
+}
+
+// ClearIndex remove all docs
+func (f *FakeEs) ClearIndex(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	idx := f.index(false)
+	if idx == nil {
+		return errors.Wrap(fakeIndexNotFound(f.esIndex), "call DeleteByQuery() error")
+	}
+	idx.docs = make(map[string]*fakeDoc)
+	return nil
+}
+
+// PutMapping updates mapping of mp.Index
+func (f *FakeEs) PutMapping(ctx context.Context, mp MappingPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	idx, ok := f.indices[mp.Index]
+	if !ok {
+		return errors.Wrap(fakeIndexNotFound(mp.Index), "call PutMapping() error")
+	}
+	for _, field := range mp.Fields {
+		idx.properties[field.Name] = field.property()
+	}
+	return nil
+}
+
+// PutMappingJson updates mapping with json data
+func (f *FakeEs) PutMappingJson(ctx context.Context, mapping string) error {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(mapping), &body); err != nil {
+		return errors.Wrap(err, "call PutMappingJson() error")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	idx := f.index(false)
+	if idx == nil {
+		return errors.Wrap(fakeIndexNotFound(f.esIndex), "call PutMappingJson() error")
+	}
+	for name, property := range fakeProperties(body) {
+		idx.properties[name] = property
+	}
+	return nil
+}
+
+// GetMapping get mapping, fields of saved docs are not mapped dynamically like es
+func (f *FakeEs) GetMapping(ctx context.Context) (map[string]interface{}, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	idx := f.index(false)
+	if idx == nil {
+		return nil, errors.Wrap(fakeIndexNotFound(f.esIndex), "call GetMapping() error")
+	}
+	mappings := make(map[string]interface{})
+	if len(idx.properties) > 0 {
+		mappings["_doc"] = map[string]interface{}{
+			"properties": idx.properties,
+		}
+	}
+	var ret map[string]interface{}
+	copier.DeepCopy(map[string]interface{}{
+		f.esIndex: map[string]interface{}{
+			"mappings": mappings,
+		},
+	}, &ret)
+	return ret, nil
+}
+
+// fakeProperties returns properties of mappings with or without type name
+func fakeProperties(mappings interface{}) map[string]interface{} {
+	m, _ := mappings.(map[string]interface{})
+	if properties, ok := m["properties"].(map[string]interface{}); ok {
+		return properties
+	}
+	for _, typed := range m {
+		if t, ok := typed.(map[string]interface{}); ok {
+			if properties, ok := t["properties"].(map[string]interface{}); ok {
+				return properties
+			}
+		}
+	}
+	return nil
+}
+
+// search returns docs of the index matching paging in order of paging.Sortby, docs after paging.SearchAfter if set
+func (f *FakeEs) search(paging *Paging) ([]fakeHit, error) {
+	if stringutils.IsNotEmpty(paging.PitID) {
+		return nil, errors.New("point in time is not supported by FakeEs, search the index instead")
+	}
+	idx := f.index(false)
+	if idx == nil {
+		return nil, fakeIndexNotFound(f.esIndex)
+	}
+	zone := time.Local
+	if stringutils.IsNotEmpty(paging.Zone) {
+		var err error
+		if zone, err = time.LoadLocation(paging.Zone); err != nil {
+			return nil, errors.Wrap(err, "call LoadLocation() error")
+		}
+	}
+	match, err := fakeQuery(paging, zone)
+	if err != nil {
+		return nil, errors.Wrap(err, "call pagingQuery() error")
+	}
+	var hits []fakeHit
+	for _, doc := range idx.docs {
+		if match(doc.id, doc.source) {
+			hits = append(hits, newFakeHit(doc, paging.Sortby))
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if c := fakeCompareSort(hits[i].sort, hits[j].sort, paging.Sortby); c != 0 {
+			return c < 0
+		}
+		return hits[i].seq < hits[j].seq
+	})
+	if len(paging.SearchAfter) > 0 {
+		var after []fakeHit
+		for _, hit := range hits {
+			if fakeCompareSort(hit.sort, paging.SearchAfter, paging.Sortby) > 0 {
+				after = append(after, hit)
+			}
+		}
+		hits = after
+	}
+	return hits, nil
+}
+
+// fakePage returns hits of the page, Skip is ignored if SearchAfter is set
+func fakePage(hits []fakeHit, paging *Paging) []fakeHit {
+	from := paging.Skip
+	if len(paging.SearchAfter) > 0 || from < 0 {
+		from = 0
+	}
+	if from > len(hits) {
+		from = len(hits)
+	}
+	to := len(hits)
+	if paging.Limit >= 0 && from+paging.Limit < to {
+		to = from + paging.Limit
+	}
+	return hits[from:to]
+}
+
+// doc returns source of the hit filtered by Includes and Excludes of paging with _id like hitToDoc
+func (h fakeHit) doc(paging *Paging) map[string]interface{} {
+	var p map[string]interface{}
+	json.Unmarshal(h.raw, &p)
+	if paging != nil {
+		p = fakeFilter(p, "", paging.Includes, paging.Excludes)
+	}
+	p["_id"] = h.id
+	if h.distance != nil {
+		p["_distance"] = *h.distance
+	}
+	return p
+}
+
+// filteredRaw returns source of the hit filtered by Includes and Excludes of paging
+func (h fakeHit) filteredRaw(paging *Paging) json.RawMessage {
+	if len(paging.Includes) == 0 && len(paging.Excludes) == 0 {
+		return h.raw
+	}
+	var p map[string]interface{}
+	json.Unmarshal(h.raw, &p)
+	raw, _ := json.Marshal(fakeFilter(p, "", paging.Includes, paging.Excludes))
+	return raw
+}
@@ -0,0 +1,440 @@
+package esutils
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupFakeEs(t *testing.T) *FakeEs {
+	es := NewFakeEs("test_fake")
+	err := es.BulkSaveOrUpdate(context.Background(), fakeDocs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+// fakeDocs returns docs of setupFakeEs
+func fakeDocs() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"id":       "1",
+			"createAt": "2020-06-01T00:00:00Z",
+			"type":     "education",
+			"text":     "高考文科综合科目考试将要结束时，一考生突然情绪失控",
+			"status":   "Open",
+			"score":    80,
+			"tags":     []string{"exam", "news"},
+			"location": map[string]interface{}{"lat": 40.1, "lon": -70.2},
+			"line":     []interface{}{map[string]interface{}{"sku": "A001", "qty": 1}, map[string]interface{}{"sku": "B002", "qty": 3}},
+		},
+		map[string]interface{}{
+			"id":       "2",
+			"createAt": "2020-06-20T00:00:00Z",
+			"type":     "sport",
+			"text":     "考场两位监考教师及时制止，并稳定了考场秩序",
+			"status":   "closed",
+			"score":    95.5,
+			"tags":     []string{"sport"},
+			"location": "40.7,-74.0",
+			"line":     []interface{}{map[string]interface{}{"sku": "A001", "qty": 5}},
+		},
+		map[string]interface{}{
+			"id":       "3",
+			"createAt": "2020-07-10T00:00:00Z",
+			"type":     "culture",
+			"text":     "Quick brown fox jumps over the lazy dog",
+			"product":  "iphone",
+			"score":    60,
+			"location": []interface{}{float64(116.4), float64(39.9)},
+		},
+	}
+}
+
+func fakeIds(docs []interface{}) []string {
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, doc.(map[string]interface{})["_id"].(string))
+	}
+	return ids
+}
+
+type fakeQueryTest struct {
+	name   string
+	paging *Paging
+	want   []string
+}
+
+// fakeQueryTests returns queries of fakeDocs and ids of the docs they match
+func fakeQueryTests() []fakeQueryTest {
+	return []fakeQueryTest{
+		{
+			name: "terms on keyword",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"type.keyword": {"sport", "culture"}}, QueryLogic: MUST, QueryType: TERMS},
+			}},
+			want: []string{"2", "3"},
+		},
+		{
+			name: "term case insensitive",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"status": {"open"}}, QueryLogic: MUST, QueryType: TERM, CaseInsensitive: true},
+			}},
+			want: []string{"1"},
+		},
+		{
+			name: "match phrase",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"text": {"考生"}}, QueryLogic: MUST, QueryType: MATCHPHRASE},
+			}},
+			want: []string{"1"},
+		},
+		{
+			name: "match phrase with plus and minus",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"text": {"考场+-高考", "lazy dog"}}, QueryLogic: MUST, QueryType: MATCHPHRASE},
+			}},
+			want: []string{"2", "3"},
+		},
+		{
+			name: "should and must not",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"tags": {"exam"}}, QueryLogic: SHOULD, QueryType: TERMS},
+				{Pair: map[string][]interface{}{"tags": {"sport"}}, QueryLogic: SHOULD, QueryType: TERMS},
+				{Pair: map[string][]interface{}{"type": {"sport"}}, QueryLogic: MUSTNOT, QueryType: TERMS},
+			}},
+			want: []string{"1"},
+		},
+		{
+			name: "root minimum should match",
+			paging: &Paging{
+				QueryConds: []QueryCond{
+					{Pair: map[string][]interface{}{"tags": {"exam"}}, QueryLogic: SHOULD, QueryType: TERMS},
+					{Pair: map[string][]interface{}{"tags": {"news"}}, QueryLogic: SHOULD, QueryType: TERMS},
+					{Pair: map[string][]interface{}{"tags": {"sport"}}, QueryLogic: SHOULD, QueryType: TERMS},
+				},
+				MinimumShouldMatch: "2",
+			},
+			want: []string{"1"},
+		},
+		{
+			name: "range",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"score": {map[string]interface{}{
+					"from": 60, "to": 95.5, "include_lower": false,
+				}}}, QueryLogic: FILTER, QueryType: RANGE},
+			}},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "date range",
+			paging: &Paging{
+				StartDate: "2020-06-01",
+				EndDate:   "2020-07-10",
+				DateField: "createAt",
+				Zone:      "UTC",
+			},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "date ranges with date math",
+			paging: &Paging{
+				DateRanges: []DateRange{{Field: "createAt", Start: "2020-06-01||+1d", End: "2020-07-10", IncludeEnd: true}},
+				Zone:       "UTC",
+			},
+			want: []string{"2", "3"},
+		},
+		{
+			name: "prefix wildcard and regexp",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"type": {"spo"}}, QueryLogic: SHOULD, QueryType: PREFIX},
+				{Pair: map[string][]interface{}{"type": {"edu*on"}}, QueryLogic: SHOULD, QueryType: WILDCARD},
+				{Pair: map[string][]interface{}{"type": {"cult.r."}}, QueryLogic: SHOULD, QueryType: REGEXP},
+			}},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name: "exists",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"product": nil}, QueryLogic: MUST, QueryType: EXISTS},
+			}},
+			want: []string{"3"},
+		},
+		{
+			name: "fuzzy",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"product": {"iphnoe"}}, QueryLogic: MUST, QueryType: FUZZY, Fuzziness: "AUTO", PrefixLength: 1},
+			}},
+			want: []string{"3"},
+		},
+		{
+			name: "ids",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"_id": {"1", 3}}, QueryLogic: MUST, QueryType: IDS},
+			}},
+			want: []string{"1", "3"},
+		},
+		{
+			name: "bool prefix",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"text": {"quick bro"}}, QueryLogic: MUST, QueryType: BOOLPREFIX},
+			}},
+			want: []string{"3"},
+		},
+		{
+			name: "nested",
+			paging: &Paging{QueryConds: []QueryCond{
+				{
+					QueryLogic: MUST,
+					QueryType:  NESTED,
+					Path:       "line",
+					Children: []QueryCond{
+						{Pair: map[string][]interface{}{"line.sku": {"A001"}}, QueryLogic: MUST, QueryType: TERMS},
+						{Pair: map[string][]interface{}{"line.qty": {map[string]interface{}{"from": 2}}}, QueryLogic: MUST, QueryType: RANGE},
+					},
+				},
+			}},
+			want: []string{"2"},
+		},
+		{
+			name: "children with minimum should match",
+			paging: &Paging{QueryConds: []QueryCond{
+				{
+					QueryLogic:         MUST,
+					MinimumShouldMatch: "2",
+					Children: []QueryCond{
+						{Pair: map[string][]interface{}{"tags": {"exam"}}, QueryLogic: SHOULD, QueryType: TERMS},
+						{Pair: map[string][]interface{}{"status": {"Open"}}, QueryLogic: SHOULD, QueryType: TERMS},
+						{Pair: map[string][]interface{}{"type": {"sport"}}, QueryLogic: SHOULD, QueryType: TERMS},
+					},
+				},
+			}},
+			want: []string{"1"},
+		},
+		{
+			name: "geo",
+			paging: &Paging{QueryConds: []QueryCond{
+				{Pair: map[string][]interface{}{"location": {map[string]interface{}{
+					"lat": float64(40), "lon": float64(-70), "distance": "200km",
+				}}}, QueryLogic: SHOULD, QueryType: GEODISTANCE},
+				{Pair: map[string][]interface{}{"location": {map[string]interface{}{
+					"top_left": GeoPoint{Lat: 41, Lon: -75}, "bottom_right": "40.5,-73",
+				}}}, QueryLogic: SHOULD, QueryType: GEOBOUNDINGBOX},
+				{Pair: map[string][]interface{}{"location": {"50,110", "50,120", "30,120", "30,110"}}, QueryLogic: MUSTNOT, QueryType: GEOPOLYGON},
+			}},
+			want: []string{"1", "2"},
+		},
+	}
+}
+
+func TestFakeEs_query(t *testing.T) {
+	es := setupFakeEs(t)
+	for _, tt := range fakeQueryTests() {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.paging.Limit = -1
+			docs, err := es.List(context.Background(), tt.paging, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, fakeIds(docs))
+			count, err := es.Count(context.Background(), tt.paging)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, int64(len(tt.want)), count)
+		})
+	}
+}
+
+func TestFakeEs_Page(t *testing.T) {
+	es := setupFakeEs(t)
+	paging := &Paging{
+		Sortby:   []Sort{{Field: "score", Ascending: false}},
+		Limit:    2,
+		Includes: []string{"type", "line.sku"},
+	}
+	pr, err := es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, pr.Total)
+	assert.Equal(t, 1, pr.Page)
+	assert.True(t, pr.HasNextPage)
+	assert.Equal(t, []string{"2", "1"}, fakeIds(pr.Docs))
+	assert.Equal(t, map[string]interface{}{
+		"_id":  "2",
+		"type": "sport",
+		"line": []interface{}{map[string]interface{}{"sku": "A001"}},
+	}, pr.Docs[0])
+
+	paging.SearchAfter = pr.SearchAfter
+	pr, err = es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"3"}, fakeIds(pr.Docs))
+	assert.Equal(t, 0, pr.Page)
+	assert.False(t, pr.HasNextPage)
+
+	pr, err = es.Page(context.Background(), &Paging{
+		Sortby: []Sort{{Field: "location", GeoPoint: &GeoPoint{Lat: 40, Lon: -70}, Unit: "km", Ascending: true}},
+		Skip:   1,
+		Limit:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, pr.Page)
+	assert.Equal(t, []string{"2"}, fakeIds(pr.Docs))
+	assert.InDelta(t, 340, pr.Docs[0].(map[string]interface{})["_distance"], 10)
+
+	pr, err = es.Page(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, pr.Total)
+
+	// fetching all docs ignores Sortby, Skip and SearchAfter like es
+	docs, err := es.List(context.Background(), &Paging{
+		Sortby:      []Sort{{Field: "score", Ascending: false}},
+		Skip:        1,
+		SearchAfter: []interface{}{80},
+		Limit:       -1,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, fakeIds(docs))
+
+	_, err = es.Page(context.Background(), &Paging{Limit: -1, Collapse: &Collapse{Field: "type"}})
+	assert.EqualError(t, errors.Cause(err), "collapse is not supported when fetching all docs, set limit between 0 and 10000")
+	_, err = es.Page(context.Background(), &Paging{Limit: 10, PitID: "p1"})
+	assert.Error(t, err)
+
+	rets, err := es.List(context.Background(), &Paging{Sortby: []Sort{{Field: "createAt"}}, Limit: 1}, func(message json.RawMessage) (interface{}, error) {
+		var doc struct {
+			Type string `json:"type"`
+		}
+		err := json.Unmarshal(message, &doc)
+		return doc.Type, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{"culture"}, rets)
+}
+
+func TestFakeEs_Stat(t *testing.T) {
+	es := setupFakeEs(t)
+	var aggr map[string]interface{}
+	json.Unmarshal([]byte(`{
+        "groupBy": {
+            "terms": {"field": "type.keyword", "size": 2},
+            "aggs": {"avgScore": {"avg": {"field": "score"}}}
+        },
+        "maxScore": {"max": {"field": "score"}}
+    }`), &aggr)
+	ret, err := es.Stat(context.Background(), nil, aggr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, _ := gabs.ParseJSON([]byte(`{
+        "groupBy": {
+            "doc_count_error_upper_bound": 0,
+            "sum_other_doc_count": 1,
+            "buckets": [
+                {"key": "culture", "doc_count": 1, "avgScore": {"value": 60}},
+                {"key": "education", "doc_count": 1, "avgScore": {"value": 80}}
+            ]
+        },
+        "maxScore": {"value": 95.5}
+    }`))
+	assert.Equal(t, expect.Data(), ret)
+
+	ret, err = es.Stat(context.Background(), &Paging{QueryConds: []QueryCond{
+		{Pair: map[string][]interface{}{"line.sku": {"A001"}}, QueryLogic: MUST, QueryType: TERMS},
+	}}, elastic.NewTermsAggregation().Field("type.keyword"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, gabs.Wrap(ret).Path("volume.buckets").Data(), 2)
+
+	_, err = es.Stat(context.Background(), nil, map[string]interface{}{
+		"histogram": map[string]interface{}{"date_histogram": map[string]interface{}{"field": "createAt"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestFakeEs_index(t *testing.T) {
+	es := NewFakeEs("test_fake_index")
+	var client EsClient = es
+	ctx := context.Background()
+
+	_, err := client.Page(ctx, &Paging{Limit: 10})
+	assert.True(t, elastic.IsNotFound(errors.Cause(err)))
+	assert.Equal(t, ErrClient, ErrorType(err))
+
+	exists, err := client.NewIndex(ctx, NewMapping(MappingPayload{
+		Base{Index: es.GetIndex()},
+		[]Field{{Name: "createAt", Type: DATE}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, exists)
+	err = client.PutMapping(ctx, MappingPayload{
+		Base{Index: es.GetIndex()},
+		[]Field{{Name: "type", Type: KEYWORD}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := client.GetMapping(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "keyword", gabs.Wrap(mapping).Path("test_fake_index.mappings._doc.properties.type.type").Data())
+
+	id, err := client.SaveOrUpdate(ctx, map[string]interface{}{"type": "sport"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, id, 20)
+	doc, err := client.GetByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"_id": id, "type": "sport"}, doc)
+
+	_, err = client.SaveOrUpdate(ctx, map[string]interface{}{"id": id, "type": "culture"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ = client.GetByID(ctx, id)
+	assert.Equal(t, "culture", doc["type"])
+
+	err = client.BulkDelete(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetByID(ctx, id)
+	assert.True(t, elastic.IsNotFound(errors.Cause(err)))
+
+	client.SaveOrUpdate(ctx, map[string]interface{}{"id": "1"})
+	if err = client.ClearIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	count, _ := client.Count(ctx, nil)
+	assert.Equal(t, int64(0), count)
+
+	if err = client.DeleteIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, client.ClearIndex(ctx))
+}
@@ -0,0 +1,1285 @@
+package esutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// fakeMatcher reports whether the doc with id and source matches a query of FakeEs
+type fakeMatcher func(id string, source map[string]interface{}) bool
+
+// fakeBool evaluates clauses like bool query
+type fakeBool struct {
+	must               []fakeMatcher
+	filter             []fakeMatcher
+	mustNot            []fakeMatcher
+	should             []fakeMatcher
+	minimumShouldMatch string
+}
+
+// attach adds m to the clause of b selected by logic
+func (b *fakeBool) attach(logic queryLogic, m fakeMatcher) {
+	switch logic {
+	case SHOULD:
+		b.should = append(b.should, m)
+	case MUST:
+		b.must = append(b.must, m)
+	case MUSTNOT:
+		b.mustNot = append(b.mustNot, m)
+	case FILTER:
+		b.filter = append(b.filter, m)
+	}
+}
+
+func (b *fakeBool) match(id string, source map[string]interface{}) bool {
+	for _, clause := range [][]fakeMatcher{b.must, b.filter} {
+		for _, m := range clause {
+			if !m(id, source) {
+				return false
+			}
+		}
+	}
+	for _, m := range b.mustNot {
+		if m(id, source) {
+			return false
+		}
+	}
+	if len(b.should) == 0 {
+		return true
+	}
+	minimum := fakeMinimumShouldMatch(b.minimumShouldMatch, len(b.should))
+	if stringutils.IsEmpty(b.minimumShouldMatch) && len(b.must)+len(b.filter) == 0 {
+		minimum = 1
+	}
+	var matched int
+	for _, m := range b.should {
+		if m(id, source) {
+			matched++
+		}
+	}
+	return matched >= minimum
+}
+
+// fakeMinimumShouldMatch returns number of should clauses required by msm like 2, -1, 75% or -25%
+func fakeMinimumShouldMatch(msm string, clauses int) int {
+	if stringutils.IsEmpty(msm) {
+		return 0
+	}
+	var n int
+	if strings.HasSuffix(msm, "%") {
+		percent, err := strconv.Atoi(strings.TrimSuffix(msm, "%"))
+		if err != nil {
+			return 1
+		}
+		n = clauses * percent / 100
+		if percent < 0 {
+			n = clauses + n
+		}
+	} else {
+		var err error
+		if n, err = strconv.Atoi(msm); err != nil {
+			return 1
+		}
+		if n < 0 {
+			n = clauses + n
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// fakeQuery returns matcher of the query of paging, invalid paging is rejected by pagingQuery like Es
+func fakeQuery(paging *Paging, zone *time.Location) (fakeMatcher, error) {
+	if _, err := pagingQuery(paging, zone); err != nil {
+		return nil, err
+	}
+	b := &fakeBool{}
+	ranges := paging.DateRanges
+	if r, ok := paging.dateRange(); ok {
+		ranges = append([]DateRange{r}, ranges...)
+	}
+	for _, r := range ranges {
+		m, err := fakeDateRange(r, zone)
+		if err != nil {
+			return nil, err
+		}
+		b.filter = append(b.filter, m)
+	}
+	for _, qc := range paging.QueryConds {
+		if qc.QueryLogic == SHOULD {
+			b.minimumShouldMatch = "1"
+			if stringutils.IsNotEmpty(paging.MinimumShouldMatch) {
+				b.minimumShouldMatch = paging.MinimumShouldMatch
+			}
+		}
+		fakeTree(b, qc)
+	}
+	return b.match, nil
+}
+
+func fakeTree(b *fakeBool, cond QueryCond) {
+	if len(cond.Children) > 0 || cond.QueryType == NESTED {
+		sub := &fakeBool{
+			minimumShouldMatch: cond.MinimumShouldMatch,
+		}
+		for _, qc := range cond.Children {
+			fakeTree(sub, qc)
+		}
+		if cond.QueryType == NESTED {
+			b.attach(cond.QueryLogic, fakeNested(cond.Path, sub))
+			return
+		}
+		b.attach(cond.QueryLogic, sub.match)
+		return
+	}
+	fakeNode(b, cond)
+}
+
+func fakeNode(b *fakeBool, qc QueryCond) {
+	for field, value := range qc.Pair {
+		if len(value) == 0 && qc.QueryType != EXISTS {
+			continue
+		}
+		var m fakeMatcher
+		switch qc.QueryType {
+		case TERMS:
+			m = fakeTerms(field, value, false)
+		case TERM:
+			m = fakeTerms(field, value[:1], qc.CaseInsensitive)
+		case RANGE:
+			m = fakeRange(field, value)
+		case MATCHPHRASE:
+			m = fakeMatchPhrase(field, value)
+		case PREFIX:
+			m = fakePrefix(field, value, qc.CaseInsensitive)
+		case WILDCARD:
+			m = fakeWildcard(field, value, qc.CaseInsensitive)
+		case EXISTS:
+			m = fakeExists(field)
+		case GEODISTANCE:
+			m = fakeGeoDistance(field, value)
+		case GEOBOUNDINGBOX:
+			m = fakeGeoBoundingBox(field, value)
+		case GEOPOLYGON:
+			m = fakeGeoPolygon(field, value)
+		case FUZZY:
+			m = fakeFuzzy(field, value, qc)
+		case REGEXP:
+			m = fakeRegexp(field, value, qc.CaseInsensitive)
+		case IDS:
+			m = fakeIDs(value)
+		case BOOLPREFIX:
+			m = fakeBoolPrefix(field, value)
+		}
+		if m != nil {
+			b.attach(qc.QueryLogic, m)
+		}
+	}
+}
+
+// fakeNested matches docs having an object at nested path p matching sub
+func fakeNested(p string, sub *fakeBool) fakeMatcher {
+	keys := strings.Split(p, ".")
+	return func(id string, source map[string]interface{}) bool {
+		for _, obj := range fakeLeaves(source, p) {
+			objs, ok := obj.([]interface{})
+			if !ok {
+				objs = []interface{}{obj}
+			}
+			for _, o := range objs {
+				if sub.match(id, fakeScoped(source, keys, o)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// fakeScoped returns copy of source with the nested field at keys replaced by obj
+func fakeScoped(source map[string]interface{}, keys []string, obj interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(source))
+	for k, v := range source {
+		ret[k] = v
+	}
+	if len(keys) == 1 {
+		ret[keys[0]] = obj
+		return ret
+	}
+	child, _ := source[keys[0]].(map[string]interface{})
+	ret[keys[0]] = fakeScoped(child, keys[1:], obj)
+	return ret
+}
+
+// fakeLeaves returns values of field, arrays of objects on the path are flattened but arrays of the field are kept.
+// Sub fields of scalar values like type.keyword are the values themselves like multi-fields of dynamic mapping
+func fakeLeaves(source map[string]interface{}, field string) []interface{} {
+	current := []interface{}{source}
+	for _, key := range strings.Split(field, ".") {
+		var next []interface{}
+		for _, v := range current {
+			switch obj := v.(type) {
+			case map[string]interface{}:
+				if value, ok := obj[key]; ok && value != nil {
+					next = append(next, value)
+				}
+			case []interface{}:
+				var scalars []interface{}
+				for _, item := range obj {
+					if m, ok := item.(map[string]interface{}); ok {
+						if value, ok := m[key]; ok && value != nil {
+							next = append(next, value)
+						}
+					} else if item != nil {
+						scalars = append(scalars, item)
+					}
+				}
+				if len(scalars) > 0 {
+					next = append(next, scalars)
+				}
+			default:
+				next = append(next, v)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// fakeValues returns values of field with arrays flattened
+func fakeValues(source map[string]interface{}, field string) []interface{} {
+	var ret []interface{}
+	var flatten func(v interface{})
+	flatten = func(v interface{}) {
+		if items, ok := v.([]interface{}); ok {
+			for _, item := range items {
+				flatten(item)
+			}
+			return
+		}
+		if v != nil {
+			ret = append(ret, v)
+		}
+	}
+	for _, v := range fakeLeaves(source, field) {
+		flatten(v)
+	}
+	return ret
+}
+
+// fakeAny returns matcher of docs having any value of field satisfying pred
+func fakeAny(field string, pred func(v interface{}) bool) fakeMatcher {
+	return func(id string, source map[string]interface{}) bool {
+		for _, v := range fakeValues(source, field) {
+			if pred(v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// fakeNumber converts numeric values to float64
+func fakeNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	}
+	return toFloat(v)
+}
+
+// fakeCompare compares a and b as numbers, dates or strings, ok is false if they are not comparable
+func fakeCompare(a, b interface{}) (c int, ok bool) {
+	na, aNum := fakeNumber(a)
+	nb, bNum := fakeNumber(b)
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	if aNum && bStr {
+		nb, bNum = fakeParseNumber(sb)
+	} else if bNum && aStr {
+		na, aNum = fakeParseNumber(sa)
+	}
+	if aNum && bNum {
+		switch {
+		case na < nb:
+			return -1, true
+		case na > nb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if aStr && bStr {
+		if ta, ok := fakeParseDate(sa); ok {
+			if tb, ok := fakeParseDate(sb); ok {
+				switch {
+				case ta.Before(tb):
+					return -1, true
+				case ta.After(tb):
+					return 1, true
+				}
+				return 0, true
+			}
+		}
+		return strings.Compare(sa, sb), true
+	}
+	if ba, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			switch {
+			case ba == bb:
+				return 0, true
+			case bb:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func fakeParseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// fakeEqual reports whether a equals b like term query on keyword or numeric fields
+func fakeEqual(a, b interface{}, caseInsensitive bool) bool {
+	if c, ok := fakeCompare(a, b); ok {
+		if _, aStr := a.(string); !aStr || !caseInsensitive {
+			return c == 0
+		}
+	}
+	sa, sb := fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)
+	if caseInsensitive {
+		return strings.EqualFold(sa, sb)
+	}
+	return sa == sb
+}
+
+func fakeTerms(field string, value []interface{}, caseInsensitive bool) fakeMatcher {
+	return fakeAny(field, func(v interface{}) bool {
+		for _, want := range value {
+			if fakeEqual(v, want, caseInsensitive) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func fakeRange(field string, value []interface{}) fakeMatcher {
+	paramsMap, ok := value[0].(map[string]interface{})
+	if !ok || (paramsMap["from"] == nil && paramsMap["to"] == nil) {
+		return nil
+	}
+	includeLower, includeUpper := true, true
+	if b, ok := paramsMap["include_lower"].(bool); ok {
+		includeLower = b
+	}
+	if b, ok := paramsMap["include_upper"].(bool); ok {
+		includeUpper = b
+	}
+	return fakeAny(field, func(v interface{}) bool {
+		if from := paramsMap["from"]; from != nil {
+			c, ok := fakeCompare(v, from)
+			if !ok || c < 0 || (c == 0 && !includeLower) {
+				return false
+			}
+		}
+		if to := paramsMap["to"]; to != nil {
+			c, ok := fakeCompare(v, to)
+			if !ok || c > 0 || (c == 0 && !includeUpper) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// fakeMatchPhrase mirrors matchPhrase, words joined by + must all match and words starting with - must not match
+func fakeMatchPhrase(field string, value []interface{}) fakeMatcher {
+	bq := &fakeBool{}
+	for _, item := range value {
+		keyword, _ := item.(string)
+		words := strings.Split(keyword, "+")
+		if len(words) > 1 {
+			nested := &fakeBool{}
+			for _, word := range words {
+				word = strings.TrimSpace(word)
+				if word != "" {
+					if word[0] != '-' {
+						nested.must = append(nested.must, fakePhrase(field, word))
+					} else {
+						nested.mustNot = append(nested.mustNot, fakePhrase(field, word[1:]))
+					}
+				}
+			}
+			bq.should = append(bq.should, nested.match)
+		} else {
+			word := words[0]
+			if word != "" {
+				if word[0] != '-' {
+					bq.should = append(bq.should, fakePhrase(field, word))
+				} else {
+					nested := &fakeBool{}
+					nested.mustNot = append(nested.mustNot, fakePhrase(field, word[1:]))
+					bq.should = append(bq.should, nested.match)
+				}
+			}
+		}
+	}
+	return bq.match
+}
+
+// fakePhrase matches docs having all words of text in a row in field
+func fakePhrase(field, text string) fakeMatcher {
+	words := fakeTokens(text)
+	return fakeAny(field, func(v interface{}) bool {
+		tokens := fakeTokens(fmt.Sprintf("%v", v))
+		if len(words) == 0 {
+			return false
+		}
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			matched := true
+			for j, word := range words {
+				if tokens[i+j] != word {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// fakeTokens splits text into lowercase words, every CJK character is a word like the standard analyzer
+func fakeTokens(text string) []string {
+	var (
+		tokens []string
+		sb     strings.Builder
+	)
+	flush := func() {
+		if sb.Len() > 0 {
+			tokens = append(tokens, sb.String())
+			sb.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// fakeString returns the first value as string
+func fakeString(value []interface{}) string {
+	if len(value) == 0 || value[0] == nil {
+		return ""
+	}
+	s, _ := value[0].(string)
+	return s
+}
+
+func fakePrefix(field string, value []interface{}, caseInsensitive bool) fakeMatcher {
+	prefix := fakeString(value)
+	if stringutils.IsEmpty(prefix) {
+		return nil
+	}
+	return fakeAny(field, func(v interface{}) bool {
+		s, ok := v.(string)
+		if caseInsensitive {
+			return ok && strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+		}
+		return ok && strings.HasPrefix(s, prefix)
+	})
+}
+
+func fakeWildcard(field string, value []interface{}, caseInsensitive bool) fakeMatcher {
+	wild := fakeString(value)
+	if stringutils.IsEmpty(wild) {
+		return nil
+	}
+	var sb strings.Builder
+	if caseInsensitive {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	for _, r := range wild {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	re := regexp.MustCompile(sb.String())
+	return fakeAny(field, func(v interface{}) bool {
+		s, ok := v.(string)
+		return ok && re.MatchString(s)
+	})
+}
+
+func fakeExists(field string) fakeMatcher {
+	if stringutils.IsEmpty(field) {
+		return nil
+	}
+	return func(id string, source map[string]interface{}) bool {
+		return len(fakeValues(source, field)) > 0
+	}
+}
+
+func fakeRegexp(field string, value []interface{}, caseInsensitive bool) fakeMatcher {
+	expr := fakeString(value)
+	if stringutils.IsEmpty(expr) {
+		return nil
+	}
+	if caseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	return fakeAny(field, func(v interface{}) bool {
+		s, ok := v.(string)
+		return err == nil && ok && re.MatchString(s)
+	})
+}
+
+func fakeIDs(value []interface{}) fakeMatcher {
+	ids := make(map[string]bool)
+	for _, item := range value {
+		ids[fmt.Sprintf("%v", item)] = true
+	}
+	return func(id string, source map[string]interface{}) bool {
+		return ids[id]
+	}
+}
+
+// fakeFuzzy matches docs having a value or a word within the edit distance of qc.Fuzziness from the term
+func fakeFuzzy(field string, value []interface{}, qc QueryCond) fakeMatcher {
+	term := []rune(fmt.Sprintf("%v", value[0]))
+	maxEdits := fakeFuzziness(qc.Fuzziness, len(term))
+	return fakeAny(field, func(v interface{}) bool {
+		s := fmt.Sprintf("%v", v)
+		for _, candidate := range append([]string{s}, fakeTokens(s)...) {
+			c := []rune(candidate)
+			if qc.PrefixLength > 0 {
+				if len(c) < qc.PrefixLength || len(term) < qc.PrefixLength ||
+					string(c[:qc.PrefixLength]) != string(term[:qc.PrefixLength]) {
+					continue
+				}
+			}
+			if fakeEditDistance(term, c) <= maxEdits {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// fakeFuzziness returns maximum edits of fuzziness like AUTO, AUTO:3,6 or 1 for term of length n
+func fakeFuzziness(fuzziness string, n int) int {
+	if edits, err := strconv.Atoi(fuzziness); err == nil {
+		return edits
+	}
+	low, high := 3, 6
+	if strings.HasPrefix(fuzziness, "AUTO:") {
+		if bounds := strings.Split(strings.TrimPrefix(fuzziness, "AUTO:"), ","); len(bounds) == 2 {
+			l, lerr := strconv.Atoi(bounds[0])
+			h, herr := strconv.Atoi(bounds[1])
+			if lerr == nil && herr == nil {
+				low, high = l, h
+			}
+		}
+	}
+	switch {
+	case n < low:
+		return 0
+	case n < high:
+		return 1
+	}
+	return 2
+}
+
+// fakeEditDistance is Damerau-Levenshtein distance of a and b with adjacent transpositions
+func fakeEditDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = fakeMin(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = fakeMin(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func fakeMin(values ...int) int {
+	ret := values[0]
+	for _, v := range values[1:] {
+		if v < ret {
+			ret = v
+		}
+	}
+	return ret
+}
+
+// fakeBoolPrefix matches docs having any word of text, the last word matches as prefix
+func fakeBoolPrefix(field string, value []interface{}) fakeMatcher {
+	text := fakeString(value)
+	if stringutils.IsEmpty(text) {
+		return nil
+	}
+	words := fakeTokens(text)
+	return fakeAny(field, func(v interface{}) bool {
+		if len(words) == 0 {
+			return false
+		}
+		last := words[len(words)-1]
+		for _, token := range fakeTokens(fmt.Sprintf("%v", v)) {
+			if strings.HasPrefix(token, last) {
+				return true
+			}
+			for _, word := range words[:len(words)-1] {
+				if token == word {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// fakeGeoPoints returns geo points of field
+func fakeGeoPoints(source map[string]interface{}, field string) []*elastic.GeoPoint {
+	var points []*elastic.GeoPoint
+	for _, v := range fakeLeaves(source, field) {
+		if point, ok := toGeoPoint(v); ok {
+			points = append(points, point)
+			continue
+		}
+		if items, ok := v.([]interface{}); ok {
+			for _, item := range items {
+				if point, ok := toGeoPoint(item); ok {
+					points = append(points, point)
+				}
+			}
+		}
+	}
+	return points
+}
+
+// fakeAnyPoint returns matcher of docs having any geo point of field satisfying pred
+func fakeAnyPoint(field string, pred func(point *elastic.GeoPoint) bool) fakeMatcher {
+	return func(id string, source map[string]interface{}) bool {
+		for _, point := range fakeGeoPoints(source, field) {
+			if pred(point) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+const fakeEarthRadius = 6371008.7714
+
+// fakeDistance returns arc distance in meters between a and b
+func fakeDistance(a, b *elastic.GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLon := lat2-lat1, (b.Lon-a.Lon)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * fakeEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+var fakeDistanceUnits = map[string]float64{
+	"":    1,
+	"m":   1,
+	"km":  1000,
+	"cm":  0.01,
+	"mm":  0.001,
+	"mi":  1609.344,
+	"yd":  0.9144,
+	"ft":  0.3048,
+	"in":  0.0254,
+	"nmi": 1852,
+	"NM":  1852,
+}
+
+var fakeDistanceExpr = regexp.MustCompile(`^\s*([0-9.]+)\s*([a-zA-Z]*)\s*$`)
+
+// fakeMeters converts distance like 10km to meters
+func fakeMeters(distance string) (float64, bool) {
+	parts := fakeDistanceExpr.FindStringSubmatch(distance)
+	if parts == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(parts[1], 64)
+	unit, ok := fakeDistanceUnits[parts[2]]
+	return n * unit, err == nil && ok
+}
+
+func fakeGeoDistance(field string, value []interface{}) fakeMatcher {
+	paramsMap, ok := value[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	center, ok := toGeoPoint(paramsMap)
+	distance, _ := paramsMap["distance"].(string)
+	meters, valid := fakeMeters(distance)
+	if !ok || !valid {
+		return nil
+	}
+	return fakeAnyPoint(field, func(point *elastic.GeoPoint) bool {
+		return fakeDistance(center, point) <= meters
+	})
+}
+
+func fakeGeoBoundingBox(field string, value []interface{}) fakeMatcher {
+	paramsMap, ok := value[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	topLeft, topLeftOk := toGeoPoint(paramsMap["top_left"])
+	bottomRight, bottomRightOk := toGeoPoint(paramsMap["bottom_right"])
+	if !topLeftOk || !bottomRightOk {
+		return nil
+	}
+	return fakeAnyPoint(field, func(point *elastic.GeoPoint) bool {
+		if point.Lat > topLeft.Lat || point.Lat < bottomRight.Lat {
+			return false
+		}
+		if topLeft.Lon <= bottomRight.Lon {
+			return point.Lon >= topLeft.Lon && point.Lon <= bottomRight.Lon
+		}
+		// the box crosses the dateline
+		return point.Lon >= topLeft.Lon || point.Lon <= bottomRight.Lon
+	})
+}
+
+func fakeGeoPolygon(field string, value []interface{}) fakeMatcher {
+	var polygon []*elastic.GeoPoint
+	for _, item := range value {
+		point, ok := toGeoPoint(item)
+		if !ok {
+			return nil
+		}
+		polygon = append(polygon, point)
+	}
+	return fakeAnyPoint(field, func(point *elastic.GeoPoint) bool {
+		inside := false
+		for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+			a, b := polygon[i], polygon[j]
+			if (a.Lat > point.Lat) != (b.Lat > point.Lat) &&
+				point.Lon < (b.Lon-a.Lon)*(point.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+				inside = !inside
+			}
+		}
+		return inside
+	})
+}
+
+// fakeDateRange returns filter of r, bounds are resolved in zone and doc dates without zone are in UTC like es
+func fakeDateRange(r DateRange, zone *time.Location) (fakeMatcher, error) {
+	now := time.Now()
+	var start, end *time.Time
+	if stringutils.IsNotEmpty(r.Start) {
+		t, err := fakeDate(r.Start, r.format(), zone, r.ExcludeStart, now)
+		if err != nil {
+			return nil, err
+		}
+		start = &t
+	}
+	if stringutils.IsNotEmpty(r.End) {
+		t, err := fakeDate(r.End, r.format(), zone, r.IncludeEnd, now)
+		if err != nil {
+			return nil, err
+		}
+		end = &t
+	}
+	return fakeAny(r.Field, func(v interface{}) bool {
+		var t time.Time
+		if ms, ok := fakeNumber(v); ok {
+			t = time.Unix(0, int64(ms)*int64(time.Millisecond))
+		} else if s, ok := v.(string); !ok {
+			return false
+		} else if t, ok = fakeParseDate(s); !ok {
+			return false
+		}
+		if start != nil && (t.Before(*start) || (r.ExcludeStart && t.Equal(*start))) {
+			return false
+		}
+		if end != nil && (t.After(*end) || (!r.IncludeEnd && t.Equal(*end))) {
+			return false
+		}
+		return true
+	}), nil
+}
+
+var fakeDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// fakeParseDate parses dates of docs in UTC unless zone is given
+func fakeParseDate(value string) (time.Time, bool) {
+	for _, layout := range fakeDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var fakeDateMathOp = regexp.MustCompile(`[+-]\d+[yMwdhHms]|/[yMwdhHms]`)
+
+// fakeDate resolves date or date math expression value of format in zone, roundUp rounds to the last millisecond
+// of rounded units and dates without time like es does for gt and lte
+func fakeDate(value, format string, zone *time.Location, roundUp bool, now time.Time) (time.Time, error) {
+	anchor, expr := value, ""
+	if strings.HasPrefix(value, "now") {
+		anchor, expr = "", strings.TrimPrefix(value, "now")
+	} else if i := strings.Index(value, "||"); i >= 0 {
+		anchor, expr = value[:i], value[i+2:]
+	}
+	t := now.In(zone)
+	if stringutils.IsNotEmpty(anchor) {
+		var (
+			ok       bool
+			dateOnly bool
+		)
+		for _, f := range strings.Split(format, "||") {
+			if t, dateOnly, ok = fakeParseFormat(anchor, strings.TrimSpace(f), zone); ok {
+				break
+			}
+		}
+		if !ok {
+			return time.Time{}, errors.Errorf("date %q of format %q is not supported by FakeEs", anchor, format)
+		}
+		if dateOnly && roundUp && stringutils.IsEmpty(expr) {
+			return t.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+		}
+	}
+	for _, op := range fakeDateMathOp.FindAllString(expr, -1) {
+		unit := op[len(op)-1]
+		if op[0] == '/' {
+			t = fakeRound(t, unit)
+			if roundUp {
+				t = fakeAddDate(t, unit, 1).Add(-time.Millisecond)
+			}
+			continue
+		}
+		n, _ := strconv.Atoi(op[1 : len(op)-1])
+		if op[0] == '-' {
+			n = -n
+		}
+		t = fakeAddDate(t, unit, n)
+	}
+	return t, nil
+}
+
+// fakeParseFormat parses value in es date format, dateOnly is true if the format has no time
+func fakeParseFormat(value, format string, zone *time.Location) (t time.Time, dateOnly bool, ok bool) {
+	switch format {
+	case "epoch_millis", "epoch_second":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, false, false
+		}
+		if format == "epoch_second" {
+			return time.Unix(n, 0), false, true
+		}
+		return time.Unix(0, n*int64(time.Millisecond)), false, true
+	case "strict_date_optional_time", "date_optional_time", "strict_date_optional_time_nanos":
+		for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05.999999999", time.RFC3339Nano} {
+			if t, err := time.ParseInLocation(layout, value, zone); err == nil {
+				return t, layout == "2006-01-02", true
+			}
+		}
+		return time.Time{}, false, false
+	}
+	layout, known := goLayout(format)
+	if !known {
+		return time.Time{}, false, false
+	}
+	t, err := time.ParseInLocation(layout, value, zone)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return t, !strings.Contains(layout, "15") && !strings.Contains(layout, "03"), true
+}
+
+// fakeRound rounds t down to unit
+func fakeRound(t time.Time, unit byte) time.Time {
+	y, m, d := t.Date()
+	switch unit {
+	case 'y':
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location())
+	case 'M':
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case 'w':
+		day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case 'd':
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case 'h', 'H':
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case 'm':
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+func fakeAddDate(t time.Time, unit byte, n int) time.Time {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0)
+	case 'M':
+		return t.AddDate(0, n, 0)
+	case 'w':
+		return t.AddDate(0, 0, 7*n)
+	case 'd':
+		return t.AddDate(0, 0, n)
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour)
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+	return t.Add(time.Duration(n) * time.Second)
+}
+
+// newFakeHit computes sort values of doc by sortby like es, missing values are nil
+func newFakeHit(doc *fakeDoc, sortby []Sort) fakeHit {
+	hit := fakeHit{
+		fakeDoc: doc,
+	}
+	for _, s := range sortby {
+		var value interface{}
+		switch {
+		case s.GeoPoint != nil:
+			center := elastic.GeoPointFromLatLon(s.GeoPoint.Lat, s.GeoPoint.Lon)
+			for _, point := range fakeGeoPoints(doc.source, s.Field) {
+				d := fakeDistance(center, point)
+				if unit, ok := fakeDistanceUnits[s.Unit]; ok {
+					d /= unit
+				}
+				if current, ok := value.(float64); !ok || d < current {
+					value = d
+				}
+			}
+			if d, ok := value.(float64); ok && hit.distance == nil {
+				hit.distance = &d
+			}
+		case s.Field == "_id":
+			value = doc.id
+		case s.Field == "_doc" || s.Field == "_shard_doc":
+			value = doc.seq
+		case s.Field == "_score":
+			value = float64(1)
+		default:
+			// es sorts multi-valued fields by the minimum value ascending and the maximum value descending
+			for _, v := range fakeValues(doc.source, s.Field) {
+				if value == nil {
+					value = v
+					continue
+				}
+				if c, ok := fakeCompare(v, value); ok && ((s.Ascending && c < 0) || (!s.Ascending && c > 0)) {
+					value = v
+				}
+			}
+		}
+		hit.sort = append(hit.sort, value)
+	}
+	return hit
+}
+
+// fakeCompareSort compares sort values a and b by sortby, missing values are the last
+func fakeCompareSort(a, b []interface{}, sortby []Sort) int {
+	for i, s := range sortby {
+		if i >= len(a) || i >= len(b) {
+			break
+		}
+		if a[i] == nil || b[i] == nil {
+			if a[i] == nil && b[i] == nil {
+				continue
+			}
+			if a[i] == nil {
+				return 1
+			}
+			return -1
+		}
+		c, ok := fakeCompare(a[i], b[i])
+		if !ok {
+			c = strings.Compare(fmt.Sprintf("%v", a[i]), fmt.Sprintf("%v", b[i]))
+		}
+		if c == 0 {
+			continue
+		}
+		if s.Ascending {
+			return c
+		}
+		return -c
+	}
+	return 0
+}
+
+// fakeFilter returns fields of source matching includes and not matching excludes, patterns support *
+func fakeFilter(source map[string]interface{}, prefix string, includes, excludes []string) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for key, value := range source {
+		p := prefix + key
+		if fakeMatchAny(excludes, p) {
+			continue
+		}
+		if len(includes) == 0 || fakeMatchAny(includes, p) {
+			if len(excludes) > 0 {
+				value = fakeFilterValue(value, p, nil, excludes)
+			}
+			ret[key] = value
+			continue
+		}
+		if sub := fakeFilterValue(value, p, includes, excludes); sub != nil {
+			ret[key] = sub
+		}
+	}
+	return ret
+}
+
+// fakeFilterValue filters objects of value at path p, it returns nil if nothing is left of an object or an array
+func fakeFilterValue(value interface{}, p string, includes, excludes []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if sub := fakeFilter(v, p+".", includes, excludes); len(sub) > 0 || len(includes) == 0 {
+			return sub
+		}
+	case []interface{}:
+		var items []interface{}
+		for _, item := range v {
+			if sub := fakeFilterValue(item, p, includes, excludes); sub != nil {
+				items = append(items, sub)
+			}
+		}
+		if len(items) > 0 || len(includes) == 0 {
+			return items
+		}
+	default:
+		if len(includes) == 0 {
+			return value
+		}
+	}
+	return nil
+}
+
+func fakeMatchAny(patterns []string, field string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, field); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeAggs evaluates aggs on hits, only terms, value_count, cardinality, sum, avg, min and max are supported
+func fakeAggs(aggs map[string]interface{}, hits []fakeHit) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	for name, definition := range aggs {
+		body, ok := definition.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid aggregation %s", name)
+		}
+		var (
+			kind   string
+			params map[string]interface{}
+			sub    map[string]interface{}
+		)
+		for key, value := range body {
+			switch key {
+			case "aggs", "aggregations":
+				sub, _ = value.(map[string]interface{})
+			case "meta":
+			default:
+				kind = key
+				params, _ = value.(map[string]interface{})
+			}
+		}
+		field, _ := params["field"].(string)
+		var (
+			result interface{}
+			err    error
+		)
+		switch kind {
+		case "terms":
+			result, err = fakeTermsAgg(field, params, sub, hits)
+		case "value_count", "cardinality", "sum", "avg", "min", "max":
+			result = fakeMetricAgg(kind, field, hits)
+		default:
+			return nil, errors.Errorf("aggregation %s of %s is not supported by FakeEs", kind, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = result
+	}
+	return ret, nil
+}
+
+// fakeTermsAgg returns buckets of the most frequent values of field, ordered by doc_count descending and key ascending
+func fakeTermsAgg(field string, params, sub map[string]interface{}, hits []fakeHit) (map[string]interface{}, error) {
+	size := 10
+	if n, ok := fakeNumber(params["size"]); ok {
+		size = int(n)
+	}
+	type bucket struct {
+		key  interface{}
+		hits []fakeHit
+	}
+	var buckets []*bucket
+	index := make(map[string]*bucket)
+	for _, hit := range hits {
+		seen := make(map[string]bool)
+		for _, v := range fakeValues(hit.source, field) {
+			k := fmt.Sprintf("%v", v)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			b, ok := index[k]
+			if !ok {
+				b = &bucket{key: v}
+				index[k] = b
+				buckets = append(buckets, b)
+			}
+			b.hits = append(b.hits, hit)
+		}
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if len(buckets[i].hits) != len(buckets[j].hits) {
+			return len(buckets[i].hits) > len(buckets[j].hits)
+		}
+		c, ok := fakeCompare(buckets[i].key, buckets[j].key)
+		return ok && c < 0
+	})
+	var other int
+	results := make([]interface{}, 0)
+	for i, b := range buckets {
+		if i >= size {
+			other += len(b.hits)
+			continue
+		}
+		result := map[string]interface{}{
+			"key":       b.key,
+			"doc_count": len(b.hits),
+		}
+		if len(sub) > 0 {
+			subResults, err := fakeAggs(sub, b.hits)
+			if err != nil {
+				return nil, err
+			}
+			for name, r := range subResults {
+				result[name] = r
+			}
+		}
+		results = append(results, result)
+	}
+	return map[string]interface{}{
+		"doc_count_error_upper_bound": 0,
+		"sum_other_doc_count":         other,
+		"buckets":                     results,
+	}, nil
+}
+
+// fakeMetricAgg returns value of metric aggregation kind on field, value is nil if there are not any numbers
+func fakeMetricAgg(kind, field string, hits []fakeHit) map[string]interface{} {
+	var (
+		count  int
+		sum    float64
+		min    = math.Inf(1)
+		max    = math.Inf(-1)
+		values = make(map[string]bool)
+	)
+	for _, hit := range hits {
+		for _, v := range fakeValues(hit.source, field) {
+			count++
+			values[fmt.Sprintf("%v", v)] = true
+			if n, ok := fakeNumber(v); ok {
+				sum += n
+				min = math.Min(min, n)
+				max = math.Max(max, n)
+			}
+		}
+	}
+	var value interface{}
+	switch kind {
+	case "value_count":
+		value = count
+	case "cardinality":
+		value = len(values)
+	case "sum":
+		value = sum
+	case "avg":
+		if count > 0 {
+			value = sum / float64(count)
+		}
+	case "min":
+		if count > 0 {
+			value = min
+		}
+	case "max":
+		if count > 0 {
+			value = max
+		}
+	}
+	return map[string]interface{}{
+		"value": value,
+	}
+}
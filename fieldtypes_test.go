@@ -0,0 +1,120 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+func TestEs_GetFieldTypes(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	acknowledged := esutilstest.Response{Body: `{"acknowledged":true}`}
+	server.Handle(http.MethodGet, "/test_fieldtypes/_mapping/_doc", esutilstest.Response{
+		Body: `{"test_fieldtypes":{"mappings":{"_doc":{"properties":{"type":{"type":"text","fields":{"keyword":{"type":"keyword"}}}}}}}}`,
+	})
+	server.Handle(http.MethodPut, "/test_fieldtypes/_mapping", acknowledged)
+	server.Handle(http.MethodHead, "/test_fieldtypes", esutilstest.NotFound("test_fieldtypes"))
+	server.Handle(http.MethodPut, "/test_fieldtypes", acknowledged)
+	server.Handle(http.MethodDelete, "/test_fieldtypes", acknowledged)
+	mappings := func() int {
+		var n int
+		for _, p := range server.Paths() {
+			if p == "GET /test_fieldtypes/_mapping/_doc" {
+				n++
+			}
+		}
+		return n
+	}
+
+	es := esutils.NewEs("test_fieldtypes", esutils.WithUrls([]string{server.URL}))
+	ctx := context.Background()
+	want := map[string]string{"type": "text", "type.keyword": "keyword"}
+	got, err := es.GetFieldTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, got)
+	got["type"] = "keyword"
+	got, err = es.GetFieldTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, mappings())
+
+	server.Handle(http.MethodPut, "/test_fieldtypes/_mapping", esutilstest.ErrorResponse(http.StatusBadRequest, "illegal_argument_exception", "bad mapping"))
+	assert.Error(t, es.PutMappingJson(ctx, `{"properties":{}}`))
+	_, err = es.GetFieldTypes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mappings())
+
+	server.Handle(http.MethodPut, "/test_fieldtypes/_mapping", acknowledged)
+	changes := []func() error{
+		func() error {
+			return es.PutMappingJson(ctx, `{"properties":{}}`)
+		},
+		func() error {
+			return es.PutMapping(ctx, esutils.MappingPayload{Base: esutils.Base{Index: "test_fieldtypes"}})
+		},
+		func() error {
+			_, err := es.NewIndex(ctx, "{}")
+			return err
+		},
+		func() error {
+			_, err := es.NewIndexOnly(ctx)
+			return err
+		},
+		func() error {
+			return es.DeleteIndex(ctx)
+		},
+	}
+	for i, change := range changes {
+		if err = change(); err != nil {
+			t.Fatal(err)
+		}
+		_, err = es.GetFieldTypes(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, i+2, mappings())
+	}
+}
+
+func TestEs_GetFieldTypes_invalidate(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	es := esutils.NewEs("test_fieldtypes", esutils.WithUrls([]string{server.URL}))
+	ctx := context.Background()
+	var fetches int
+	server.HandleFunc(http.MethodGet, "/test_fieldtypes/_mapping/_doc", func(r esutilstest.Request) esutilstest.Response {
+		fetches++
+		if fetches == 1 {
+			// the mapping changes while it is fetched
+			es.InvalidateMappingCache()
+		}
+		return esutilstest.Response{
+			Body: `{"test_fieldtypes":{"mappings":{"_doc":{"properties":{"type":{"type":"keyword"}}}}}}`,
+		}
+	})
+	server.Handle(http.MethodPut, "/other/_mapping", esutilstest.Response{Body: `{"acknowledged":true}`})
+
+	for i := 0; i < 3; i++ {
+		got, err := es.GetFieldTypes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]string{"type": "keyword"}, got)
+	}
+	assert.Equal(t, 2, fetches)
+
+	// putting the mapping of another index keeps the cache
+	if err := es.PutMapping(ctx, esutils.MappingPayload{Base: esutils.Base{Index: "other"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := es.GetFieldTypes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetches)
+}
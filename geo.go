@@ -0,0 +1,139 @@
+package esutils
+
+import (
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// GeoPoint defines a geo point by latitude and longitude
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toGeoPoint accepts GeoPoint, map with lat and lon keys, "lat,lon" string and [lon, lat] array as geo point
+func toGeoPoint(v interface{}) (*elastic.GeoPoint, bool) {
+	switch p := v.(type) {
+	case GeoPoint:
+		return elastic.GeoPointFromLatLon(p.Lat, p.Lon), true
+	case *GeoPoint:
+		if p == nil {
+			return nil, false
+		}
+		return elastic.GeoPointFromLatLon(p.Lat, p.Lon), true
+	case map[string]interface{}:
+		lat, latOk := toFloat(p["lat"])
+		lon, lonOk := toFloat(p["lon"])
+		if !latOk || !lonOk {
+			return nil, false
+		}
+		return elastic.GeoPointFromLatLon(lat, lon), true
+	case string:
+		point, err := elastic.GeoPointFromString(strings.ReplaceAll(p, " ", ""))
+		if err != nil {
+			return nil, false
+		}
+		return point, true
+	case []float64:
+		if len(p) != 2 {
+			return nil, false
+		}
+		return elastic.GeoPointFromLatLon(p[1], p[0]), true
+	case []interface{}:
+		if len(p) != 2 {
+			return nil, false
+		}
+		lon, lonOk := toFloat(p[0])
+		lat, latOk := toFloat(p[1])
+		if !latOk || !lonOk {
+			return nil, false
+		}
+		return elastic.GeoPointFromLatLon(lat, lon), true
+	}
+	return nil, false
+}
+
+// geoDistance value should be a map like {"lat": 40, "lon": -70, "distance": "10km"}, distance_type is optional
+func geoDistance(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	paramsMap, ok := value[0].(map[string]interface{})
+	if !ok {
+		return
+	}
+	point, ok := toGeoPoint(paramsMap)
+	distance, _ := paramsMap["distance"].(string)
+	if !ok || stringutils.IsEmpty(distance) {
+		return
+	}
+	geoQuery := elastic.NewGeoDistanceQuery(field).GeoPoint(point).Distance(distance)
+	if distanceType, _ := paramsMap["distance_type"].(string); stringutils.IsNotEmpty(distanceType) {
+		geoQuery.DistanceType(distanceType)
+	}
+	attach(boolQuery, qc.QueryLogic, geoQuery)
+}
+
+// geoBoundingBox value should be a map like {"top_left": {"lat": 40, "lon": -74}, "bottom_right": {"lat": 40.01, "lon": -71.12}}
+func geoBoundingBox(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	paramsMap, ok := value[0].(map[string]interface{})
+	if !ok {
+		return
+	}
+	topLeft, topLeftOk := toGeoPoint(paramsMap["top_left"])
+	bottomRight, bottomRightOk := toGeoPoint(paramsMap["bottom_right"])
+	if !topLeftOk || !bottomRightOk {
+		return
+	}
+	geoQuery := elastic.NewGeoBoundingBoxQuery(field).TopLeftFromGeoPoint(topLeft).BottomRightFromGeoPoint(bottomRight)
+	attach(boolQuery, qc.QueryLogic, geoQuery)
+}
+
+// geoPolygon value should be the points of the polygon
+func geoPolygon(boolQuery *elastic.BoolQuery, qc QueryCond, field string, value []interface{}) {
+	geoQuery := elastic.NewGeoPolygonQuery(field)
+	for _, item := range value {
+		point, ok := toGeoPoint(item)
+		if !ok {
+			return
+		}
+		geoQuery.AddGeoPoint(point)
+	}
+	attach(boolQuery, qc.QueryLogic, geoQuery)
+}
+
+func (s Sort) sorter() elastic.Sorter {
+	if s.GeoPoint != nil {
+		geoSort := elastic.NewGeoDistanceSort(s.Field).Point(s.GeoPoint.Lat, s.GeoPoint.Lon).Order(s.Ascending)
+		if stringutils.IsNotEmpty(s.Unit) {
+			geoSort = geoSort.Unit(s.Unit)
+		}
+		return geoSort
+	}
+	return elastic.NewFieldSort(s.Field).Order(s.Ascending)
+}
+
+// geoSortIndex returns index of the first geo distance sort in sortby, or -1 if there is not any
+func geoSortIndex(sortby []Sort) int {
+	for i, s := range sortby {
+		if s.GeoPoint != nil {
+			return i
+		}
+	}
+	return -1
+}
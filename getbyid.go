@@ -8,10 +8,14 @@ import (
 )
 
 // GetByID gets a doc by id
-func (es *Es) GetByID(ctx context.Context, id string) (map[string]interface{}, error) {
+func (es *Es) GetByID(ctx context.Context, id string) (doc map[string]interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "GetByID", &Call{IDs: []string{id}})
+	defer func() { err = op.end(doc, err) }()
+	if err != nil {
+		return nil, err
+	}
 	var (
 		getResult *elastic.GetResult
-		err       error
 	)
 	if getResult, err = es.client.Get().Index(es.esIndex).Type(es.esType).Id(id).Do(ctx); err != nil {
 		return nil, errors.Wrap(err, "call Get() error")
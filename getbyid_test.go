@@ -7,7 +7,7 @@ import (
 )
 
 func TestEs_GetByID(t *testing.T) {
-	es := setupSubTest("test_getbyid")
+	es := setupSubTest(t)
 	doc, _ := es.GetByID(context.Background(), "9seTXHoBNx091WJ2QCh5")
 	assert.NotZero(t, doc)
 }
@@ -12,6 +12,12 @@ require (
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 )
 
-require github.com/olivere/elastic/v7 v7.0.32
+require (
+	github.com/olivere/elastic/v7 v7.0.32
+	github.com/prometheus/client_golang v1.11.1
+	go.opentelemetry.io/otel v1.5.0
+	go.opentelemetry.io/otel/sdk v1.5.0
+	go.opentelemetry.io/otel/trace v1.5.0
+)
 
 replace github.com/olivere/elastic/v7 v7.0.32 => github.com/wubin1989/elastic/v7 v7.0.33
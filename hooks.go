@@ -0,0 +1,116 @@
+package esutils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly is returned by write operations blocked by ReadOnly hook
+var ErrReadOnly = errors.New("es is in read-only mode")
+
+// Call is an Es operation passed to hooks
+type Call struct {
+	// Operation is the name of the Es method, e.g. Page or BulkSaveOrUpdate
+	Operation string
+	// Index is the es index of the operation
+	Index string
+	// Write is true for operations changing docs, mappings or indices, e.g. SaveOrUpdate, BulkDelete and PutMapping
+	Write bool
+	// Paging is the query of Page, List, Count, Stat, Random, Sample and Explain, Before may modify or replace it
+	Paging *Paging
+	// Docs are docs of SaveOrUpdate and BulkSaveOrUpdate, Before may modify or replace elements of it,
+	// SaveOrUpdate fails if Before leaves other than one doc
+	Docs []interface{}
+	// IDs are ids of GetByID, BulkDelete and Explain
+	IDs []string
+	// Body is the mapping of NewIndex, PutMapping and PutMappingJson, or the aggregation of Stat and Sample
+	Body interface{}
+	// Header is added to every http request of the operation by Before, e.g. tenant headers.
+	// It is only sent by the default client, an error is logged once if it is set for the client of WithClient
+	Header http.Header
+	// Timeout cancels the operation after it if set by Before
+	Timeout time.Duration
+	// Response is the result of the operation for After, e.g. PageResult of Page, nil for operations returning only error
+	Response interface{}
+	// Err is the error of the operation for After, After may replace it and the operation returns the replaced one
+	Err error
+}
+
+// Hook is called around Es operations, operations called by other operations like List called by Page are not hooked
+type Hook struct {
+	// Before is called before the operation in order of WithHooks, a non-nil error short-circuits the operation:
+	// later Before are not called and the error is returned by the operation
+	Before func(ctx context.Context, call *Call) error
+	// After is called after the operation in reverse order of WithHooks, only for hooks whose Before was called,
+	// including the short-circuited ones
+	After func(ctx context.Context, call *Call)
+}
+
+// WithHooks appends hooks called around every Es operation, e.g. for tenant headers, timeouts and audit logs
+func WithHooks(hooks ...Hook) EsOption {
+	return func(es *Es) {
+		es.hooks = append(es.hooks, hooks...)
+	}
+}
+
+// ReadOnly returns a Hook failing write operations with ErrReadOnly while readOnly returns true, e.g. during maintenance
+func ReadOnly(readOnly func() bool) Hook {
+	return Hook{
+		Before: func(ctx context.Context, call *Call) error {
+			if call.Write && readOnly() {
+				return ErrReadOnly
+			}
+			return nil
+		},
+	}
+}
+
+// before calls Before of hooks until one of them returns error
+func (op *operation) before() error {
+	for _, hook := range op.es.hooks {
+		op.hooked++
+		if hook.Before == nil {
+			continue
+		}
+		if err := hook.Before(op.ctx, op.call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after calls After of hooks whose Before was called and returns the error of the call
+func (op *operation) after(response interface{}, err error) error {
+	op.call.Response = response
+	op.call.Err = err
+	for i := op.hooked - 1; i >= 0; i-- {
+		if after := op.es.hooks[i].After; after != nil {
+			after(op.ctx, op.call)
+		}
+	}
+	return op.call.Err
+}
+
+// headerTransport adds Call.Header of the operation in request context to requests
+type headerTransport struct {
+	next http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	op := operationFrom(req.Context())
+	if op == nil || len(op.call.Header) == 0 {
+		return next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for key, values := range op.call.Header {
+		req.Header[key] = values
+	}
+	return next.RoundTrip(req)
+}
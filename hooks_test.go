@@ -0,0 +1,214 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+// newHookServer returns a server answering searches and docs of test_hooks
+func newHookServer() *esutilstest.Server {
+	server := esutilstest.NewServer()
+	sport := map[string]interface{}{"_id": "1", "type": "sport"}
+	handleSearch(server, "test_hooks", esutilstest.SearchResponse(1, sport), sport)
+	server.Handle(http.MethodPut, "/test_hooks/_doc/1", esutilstest.Response{
+		Body: `{"_index":"test_hooks","_id":"1","result":"created"}`,
+	})
+	server.Handle(http.MethodGet, "/test_hooks/_doc/1", esutilstest.Response{
+		Body: `{"_index":"test_hooks","_id":"1","found":true,"_source":{}}`,
+	})
+	return server
+}
+
+// headers returns values of header name of requests received by server
+func headers(server *esutilstest.Server, name string) []string {
+	var values []string
+	for _, r := range server.Requests() {
+		values = append(values, r.Header.Get(name))
+	}
+	return values
+}
+
+func TestWithHooks(t *testing.T) {
+	server := newHookServer()
+	defer server.Close()
+
+	var calls []string
+	var pr interface{}
+	es := esutils.NewEs("test_hooks", esutils.WithUrls([]string{server.URL}), esutils.WithHooks(
+		esutils.Hook{
+			Before: func(ctx context.Context, call *esutils.Call) error {
+				calls = append(calls, "before1 "+call.Operation+" "+call.Index)
+				call.Header.Set("X-Tenant", "acme")
+				return nil
+			},
+			After: func(ctx context.Context, call *esutils.Call) {
+				calls = append(calls, "after1 "+call.Operation)
+				pr = call.Response
+			},
+		},
+		esutils.Hook{
+			Before: func(ctx context.Context, call *esutils.Call) error {
+				calls = append(calls, "before2 "+call.Operation)
+				if call.Paging != nil && call.Paging.Limit > 1 {
+					call.Paging.Limit = 1
+				}
+				return nil
+			},
+			After: func(ctx context.Context, call *esutils.Call) {
+				calls = append(calls, "after2 "+call.Operation)
+			},
+		},
+	))
+	got, err := es.Page(context.Background(), &esutils.Paging{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"before1 Page test_hooks", "before2 Page", "after2 Page", "after1 Page"}, calls)
+	assert.Equal(t, got, pr)
+	assert.Equal(t, 1, got.PageSize)
+	assert.Equal(t, []string{"acme"}, headers(server, "X-Tenant"))
+
+	// List called by Page isn't hooked but sends headers of Page
+	calls = nil
+	server.Reset()
+	_, err = es.Page(context.Background(), &esutils.Paging{Limit: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"before1 Page test_hooks", "before2 Page", "after2 Page", "after1 Page"}, calls)
+	assert.Equal(t, []string{"POST /test_hooks/_doc/_search", "POST /_search/scroll", "DELETE /_search/scroll"}, server.Paths())
+	assert.Equal(t, []string{"acme", "acme", "acme"}, headers(server, "X-Tenant"))
+}
+
+func TestReadOnly(t *testing.T) {
+	server := newHookServer()
+	defer server.Close()
+
+	readOnly := true
+	var errs []error
+	es := esutils.NewEs("test_hooks", esutils.WithUrls([]string{server.URL}), esutils.WithHooks(
+		esutils.ReadOnly(func() bool { return readOnly }),
+		esutils.Hook{
+			After: func(ctx context.Context, call *esutils.Call) {
+				errs = append(errs, call.Err)
+			},
+		},
+	))
+	_, err := es.SaveOrUpdate(context.Background(), map[string]interface{}{"id": "1"})
+	assert.Equal(t, esutils.ErrReadOnly, errors.Cause(err))
+	err = es.BulkDelete(context.Background(), []string{"1"})
+	assert.Equal(t, esutils.ErrReadOnly, errors.Cause(err))
+	assert.Empty(t, server.Paths())
+	// After of hooks after the short-circuiting one isn't called
+	assert.Empty(t, errs)
+
+	_, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, server.Paths(), 1)
+
+	readOnly = false
+	id, err := es.SaveOrUpdate(context.Background(), map[string]interface{}{"id": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", id)
+}
+
+func TestHook_modify(t *testing.T) {
+	server := newHookServer()
+	server.Handle(http.MethodPost, "/test_hooks/_doc/_count", esutilstest.Response{
+		Body:  `{"count":1}`,
+		Delay: 50 * time.Millisecond,
+	})
+	defer server.Close()
+
+	failure := errors.New("audit failed")
+	es := esutils.NewEs("test_hooks", esutils.WithUrls([]string{server.URL}), esutils.WithHooks(
+		esutils.Hook{
+			Before: func(ctx context.Context, call *esutils.Call) error {
+				for i, doc := range call.Docs {
+					doc.(map[string]interface{})["tenant"] = "acme"
+					call.Docs[i] = doc
+				}
+				if call.Operation == "Count" {
+					call.Timeout = 10 * time.Millisecond
+				}
+				return nil
+			},
+			After: func(ctx context.Context, call *esutils.Call) {
+				if call.Operation == "GetByID" {
+					call.Err = failure
+				}
+			},
+		},
+	))
+	_, err := es.SaveOrUpdate(context.Background(), map[string]interface{}{"id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(server.Requests()[0].Body), `"tenant":"acme"`)
+
+	_, err = es.Count(context.Background(), nil)
+	assert.Equal(t, esutils.ErrTimeout, esutils.ErrorType(err))
+
+	_, err = es.GetByID(context.Background(), "1")
+	assert.Equal(t, failure, err)
+}
+
+func TestHook_dropDocs(t *testing.T) {
+	server := newHookServer()
+	defer server.Close()
+
+	es := esutils.NewEs("test_hooks", esutils.WithUrls([]string{server.URL}), esutils.WithHooks(
+		esutils.Hook{
+			Before: func(ctx context.Context, call *esutils.Call) error {
+				call.Docs = nil
+				return nil
+			},
+		},
+	))
+	_, err := es.SaveOrUpdate(context.Background(), map[string]interface{}{"id": "1"})
+	assert.EqualError(t, err, "SaveOrUpdate must have exactly one doc, got 0")
+	assert.Empty(t, server.Paths())
+}
+
+func TestWithHooks_client(t *testing.T) {
+	server := newHookServer()
+	defer server.Close()
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+	tenant := false
+	es := esutils.NewEs("test_hooks", esutils.WithClient(client), esutils.WithLogger(logger), esutils.WithHooks(
+		esutils.ReadOnly(func() bool { return false }),
+		esutils.Hook{
+			Before: func(ctx context.Context, call *esutils.Call) error {
+				if tenant {
+					call.Header.Set("X-Tenant", "acme")
+				}
+				return nil
+			},
+		},
+	))
+	_, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, logger.logs["error"])
+
+	tenant = true
+	for i := 0; i < 2; i++ {
+		_, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, logger.logs["error"], 1) {
+		assert.Contains(t, logger.logs["error"][0], "Call.Header")
+	}
+	assert.Equal(t, []string{"", "", ""}, headers(server, "X-Tenant"))
+}
@@ -10,9 +10,14 @@ import (
 )
 
 // List fetch docs by paging
-func (es *Es) List(ctx context.Context, paging *Paging, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
+func (es *Es) List(ctx context.Context, paging *Paging, callback func(message json.RawMessage) (interface{}, error)) (rets []interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "List", &Call{Paging: paging})
+	defer func() { err = op.end(rets, err) }()
+	if err != nil {
+		return nil, err
+	}
+	paging = op.call.Paging
 	var (
-		err       error
 		boolQuery *elastic.BoolQuery
 	)
 	if paging == nil {
@@ -28,7 +33,9 @@ func (es *Es) List(ctx context.Context, paging *Paging, callback func(message js
 			return nil, errors.Wrap(err, "call LoadLocation() error")
 		}
 	}
-	boolQuery = query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, zone)
+	if boolQuery, err = pagingQuery(paging, zone); err != nil {
+		return nil, errors.Wrap(err, "call pagingQuery() error")
+	}
 	fsc := elastic.NewFetchSourceContext(true)
 	if len(paging.Includes) > 0 {
 		fsc = fsc.Include(paging.Includes...)
@@ -36,19 +43,23 @@ func (es *Es) List(ctx context.Context, paging *Paging, callback func(message js
 	if len(paging.Excludes) > 0 {
 		fsc = fsc.Exclude(paging.Excludes...)
 	}
-	var rets []interface{}
+	esQuery := scoreQuery(boolQuery, paging.Relevance)
 	if paging.Limit < 0 || paging.Limit > 10000 {
-		scrollSize := paging.ScrollSize
-		if scrollSize <= 0 {
-			scrollSize = 1000
+		if paging.Collapse != nil {
+			return nil, errors.New("collapse is not supported when fetching all docs, set limit between 0 and 10000")
 		}
-		if rets, err = es.fetchAll(fsc, boolQuery, scrollSize, callback); err != nil {
+		if stringutils.IsNotEmpty(paging.PitID) {
+			if rets, err = es.fetchAllPIT(ctx, fsc, paging, esQuery, callback); err != nil {
+				return nil, errors.Wrap(err, "call es.fetchAllPIT error")
+			}
+		} else if rets, err = es.fetchAll(ctx, fsc, paging, esQuery, callback); err != nil {
 			return nil, errors.Wrap(err, "call es.fetchAll error")
 		}
 	} else {
-		if rets, err = es.doPaging(ctx, fsc, paging, boolQuery, callback); err != nil {
+		if rets, err = es.doPaging(ctx, fsc, paging, esQuery, callback); err != nil {
 			return nil, errors.Wrap(err, "call es.fetchAll error")
 		}
 	}
+	recordHits(ctx, len(rets))
 	return rets, nil
 }
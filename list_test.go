@@ -8,7 +8,7 @@ import (
 )
 
 func TestList(t *testing.T) {
-	es := setupSubTest("test_list")
+	es := setupSubTest(t)
 	type args struct {
 		paging   *Paging
 		esIndex  string
@@ -81,3 +81,22 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+func TestList_slicedScroll(t *testing.T) {
+	es := setupSubTest(t)
+	got, err := es.List(context.Background(), &Paging{
+		Limit:             -1,
+		ScrollSize:        1,
+		ScrollSlices:      2,
+		ScrollConcurrency: 2,
+		ScrollKeepAlive:   "30s",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []interface{}
+	for _, item := range got {
+		ids = append(ids, item.(map[string]interface{})["_id"])
+	}
+	assert.ElementsMatch(t, []interface{}{"9seTXHoBNx091WJ2QCh5", "9seTXHoBNx091WJ2QCh6", "9seTXHoBNx091WJ2QCh7"}, ids)
+}
@@ -1,15 +1,110 @@
 package esutils
 
 import (
-	"github.com/sirupsen/logrus"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
-func newLogger(level logrus.Level) *logrus.Logger {
-	logger := logrus.New()
-	formatter := new(logrus.TextFormatter)
-	formatter.TimestampFormat = "2006-01-02 15:04:05"
-	formatter.FullTimestamp = true
-	logger.SetFormatter(formatter)
-	logger.SetLevel(level)
-	return logger
+// Logger logs messages of Es and the elastic client. *logrus.Logger and *zap.SugaredLogger implement it as they are,
+// use NewSlogLogger for *slog.Logger
+type Logger interface {
+	Errorf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+}
+
+// traceLogger is implemented by loggers having a level below debug like *logrus.Logger
+type traceLogger interface {
+	Tracef(format string, args ...interface{})
+}
+
+// printfLogger adapts a level of Logger to elastic.Logger
+type printfLogger func(format string, args ...interface{})
+
+func (f printfLogger) Printf(format string, args ...interface{}) {
+	f(format, args...)
+}
+
+// tracef logs at trace level if logger supports it, otherwise at debug level
+func tracef(logger Logger) printfLogger {
+	if l, ok := logger.(traceLogger); ok {
+		return l.Tracef
+	}
+	return logger.Debugf
+}
+
+// traceTransport logs method, url and body of requests and status and body of responses, headers are left out
+// as they carry credentials like Authorization
+type traceTransport struct {
+	next http.RoundTripper
+	logf printfLogger
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	var body string
+	if req.Body != nil {
+		raw, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "call ReadAll() error")
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		body = plainBody(raw, req.Header)
+	}
+	t.logf("%s %s\n%s\n", req.Method, req.URL.Redacted(), body)
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "call ReadAll() error")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	t.logf("%s\n%s\n", resp.Status, plainBody(raw, resp.Header))
+	return resp, nil
+}
+
+// stdLogger is the default Logger writing info and error messages to stderr
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+func newLogger(debug bool) *stdLogger {
+	return &stdLogger{
+		logger: log.New(os.Stderr, "", 0),
+		debug:  debug,
+	}
+}
+
+func (l *stdLogger) output(level, format string, args ...interface{}) {
+	l.logger.Printf("%s [%s] %s", time.Now().Format("2006-01-02 15:04:05"), level, fmt.Sprintf(format, args...))
+}
+
+func (l *stdLogger) Errorf(format string, args ...interface{}) {
+	l.output("ERROR", format, args...)
+}
+
+func (l *stdLogger) Infof(format string, args ...interface{}) {
+	l.output("INFO", format, args...)
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) {
+	if l.debug {
+		l.output("DEBUG", format, args...)
+	}
 }
@@ -0,0 +1,114 @@
+package esutils_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+var _ esutils.Logger = logrus.StandardLogger()
+
+// recordLogger records messages by level
+type recordLogger struct {
+	mu   sync.Mutex
+	logs map[string][]string
+}
+
+func (l *recordLogger) record(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.logs == nil {
+		l.logs = make(map[string][]string)
+	}
+	l.logs[level] = append(l.logs[level], fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Errorf(format string, args ...interface{}) { l.record("error", format, args...) }
+func (l *recordLogger) Infof(format string, args ...interface{})  { l.record("info", format, args...) }
+func (l *recordLogger) Debugf(format string, args ...interface{}) { l.record("debug", format, args...) }
+
+// sportPaging matches docs of type sport
+var sportPaging = &esutils.Paging{
+	QueryConds: []esutils.QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"type": {"sport"},
+			},
+			QueryLogic: esutils.MUST,
+			QueryType:  esutils.TERMS,
+		},
+	},
+}
+
+func TestWithTraceLog(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/test_log/_doc/_count", esutilstest.Response{Body: `{"count":3}`})
+
+	logger := &recordLogger{}
+	es := esutils.NewEs("test_log", esutils.WithUrls([]string{server.URL}), esutils.WithLogger(logger), esutils.WithTraceLog(true))
+	_, err := es.Count(context.Background(), sportPaging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(logger.logs["debug"], "\n")
+	assert.Contains(t, all, `"terms":{"type":["sport"]}`)
+	assert.Contains(t, all, `{"count":3}`)
+
+	logger = &recordLogger{}
+	es = esutils.NewEs("test_log", esutils.WithUrls([]string{server.URL}), esutils.WithLogger(logger))
+	_, err = es.Count(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, logger.logs["debug"])
+}
+
+func TestWithTraceLog_redact(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/test_log/_doc/_count", esutilstest.Response{Body: `{"count":3}`})
+
+	tests := []struct {
+		name   string
+		opts   []esutils.EsOption
+		header string
+		secret string
+	}{
+		{"basic auth", []esutils.EsOption{esutils.WithUsername("elastic"), esutils.WithPassword("s3cret")}, "Basic ZWxhc3RpYzpzM2NyZXQ=", "ZWxhc3RpYzpzM2NyZXQ="},
+		{"api key", []esutils.EsOption{esutils.WithAPIKey("aWQ6a2V5")}, "ApiKey aWQ6a2V5", "aWQ6a2V5"},
+		{"bearer token", []esutils.EsOption{esutils.WithBearerToken("t0ken")}, "Bearer t0ken", "t0ken"},
+		{"header", []esutils.EsOption{esutils.WithHeader("X-Api-Secret", "h3ader")}, "", "h3ader"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Reset()
+			logger := &recordLogger{}
+			opts := append([]esutils.EsOption{esutils.WithUrls([]string{server.URL}), esutils.WithLogger(logger), esutils.WithTraceLog(true)}, tt.opts...)
+			es := esutils.NewEs("test_log", opts...)
+			_, err := es.Count(context.Background(), sportPaging)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				for _, r := range server.Requests() {
+					assert.Equal(t, tt.header, r.Header.Get("Authorization"))
+				}
+			}
+			all := strings.Join(logger.logs["debug"], "\n")
+			assert.Contains(t, all, "/test_log/_doc/_count")
+			assert.Contains(t, all, `"terms":{"type":["sport"]}`)
+			assert.Contains(t, all, `{"count":3}`)
+			assert.NotContains(t, all, tt.secret)
+			assert.NotContains(t, all, "Authorization")
+		})
+	}
+}
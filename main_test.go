@@ -0,0 +1,25 @@
+package esutils_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("TZ", "Asia/Shanghai")
+	cluster, err := esutilstest.Start(context.Background(), esutilstest.WithLogger(logrus.New()))
+	if err != nil {
+		panic(err)
+	}
+	esutils.TestCluster = cluster
+	code := m.Run()
+	if err = cluster.Terminate(context.Background()); err != nil {
+		logrus.Error(err)
+	}
+	os.Exit(code)
+}
@@ -28,7 +28,7 @@ func NewMapping(mp MappingPayload) string {
 
 	properties = gabs.New()
 	for _, f := range mp.Fields {
-		properties.Set(f.Type, f.Name, "type")
+		properties.Set(f.property(), f.Name)
 	}
 
 	esType := mp.Type
@@ -41,22 +41,29 @@ func NewMapping(mp MappingPayload) string {
 }
 
 // PutMapping updates mapping
-func (es *Es) PutMapping(ctx context.Context, mp MappingPayload) error {
+func (es *Es) PutMapping(ctx context.Context, mp MappingPayload) (err error) {
+	ctx, op, err := es.begin(ctx, "PutMapping", &Call{Write: true, Body: mp})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
 	var (
 		mapping    *gabs.Container
 		properties *gabs.Container
 		res        *elastic.PutMappingResponse
-		err        error
 	)
 	mapping = gabs.New()
 	properties = gabs.New()
 	for _, f := range mp.Fields {
-		properties.Set(f.Type, f.Name, "type")
+		properties.Set(f.property(), f.Name)
 	}
 	mapping.Set(properties, "properties")
 	if res, err = es.client.PutMapping().Index(mp.Index).IncludeTypeName(false).BodyString(mapping.String()).Do(ctx); err != nil {
 		return errors.Wrap(err, "call PutMapping() error")
 	}
+	if mp.Index == es.esIndex {
+		es.InvalidateMappingCache()
+	}
 	if !res.Acknowledged {
 		return errors.New("putmapping failed!!!")
 	}
@@ -64,10 +71,14 @@ func (es *Es) PutMapping(ctx context.Context, mp MappingPayload) error {
 }
 
 // GetMapping get mapping
-func (es *Es) GetMapping(ctx context.Context) (map[string]interface{}, error) {
+func (es *Es) GetMapping(ctx context.Context) (mapping map[string]interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "GetMapping", nil)
+	defer func() { err = op.end(mapping, err) }()
+	if err != nil {
+		return nil, err
+	}
 	var (
 		res map[string]interface{}
-		err error
 	)
 	if res, err = es.client.GetMapping().Index(es.esIndex).Type(es.esType).IncludeTypeName(true).Do(ctx); err != nil {
 		return nil, errors.Wrap(err, "call GetMapping() error")
@@ -76,16 +87,97 @@ func (es *Es) GetMapping(ctx context.Context) (map[string]interface{}, error) {
 }
 
 // PutMappingJson updates mapping with json data
-func (es *Es) PutMappingJson(ctx context.Context, mapping string) error {
+func (es *Es) PutMappingJson(ctx context.Context, mapping string) (err error) {
+	ctx, op, err := es.begin(ctx, "PutMappingJson", &Call{Write: true, Body: mapping})
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
 	var (
 		res *elastic.PutMappingResponse
-		err error
 	)
 	if res, err = es.client.PutMapping().Index(es.esIndex).IncludeTypeName(false).BodyString(mapping).Do(ctx); err != nil {
 		return errors.Wrap(err, "call PutMappingJson() error")
 	}
+	es.InvalidateMappingCache()
 	if !res.Acknowledged {
 		return errors.New("putmapping failed!!!")
 	}
 	return nil
 }
+
+// GetFieldTypes returns field types of the index keyed by full field path such as user.name and text.keyword,
+// the result is a copy of the cache kept until the mapping or the index is changed by es or InvalidateMappingCache is called
+func (es *Es) GetFieldTypes(ctx context.Context) (map[string]string, error) {
+	es.mappingMu.Lock()
+	cached, gen := es.fieldTypes, es.mappingGen
+	es.mappingMu.Unlock()
+	if cached != nil {
+		return copyFieldTypes(cached), nil
+	}
+	res, err := es.GetMapping(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "call GetMapping() error")
+	}
+	fieldTypes := make(map[string]string)
+	for _, index := range res {
+		indexMapping, _ := index.(map[string]interface{})
+		mappings, _ := indexMapping["mappings"].(map[string]interface{})
+		if _, ok := mappings["properties"]; ok {
+			flattenProperties(fieldTypes, "", mappings)
+			continue
+		}
+		for _, typeMapping := range mappings {
+			if m, ok := typeMapping.(map[string]interface{}); ok {
+				flattenProperties(fieldTypes, "", m)
+			}
+		}
+	}
+	es.mappingMu.Lock()
+	if es.mappingGen == gen {
+		es.fieldTypes = fieldTypes
+	}
+	es.mappingMu.Unlock()
+	return copyFieldTypes(fieldTypes), nil
+}
+
+func copyFieldTypes(fieldTypes map[string]string) map[string]string {
+	ret := make(map[string]string, len(fieldTypes))
+	for k, v := range fieldTypes {
+		ret[k] = v
+	}
+	return ret
+}
+
+// InvalidateMappingCache drops field types cached by GetFieldTypes
+func (es *Es) InvalidateMappingCache() {
+	es.mappingMu.Lock()
+	defer es.mappingMu.Unlock()
+	es.fieldTypes = nil
+	es.mappingGen++
+}
+
+func flattenProperties(fieldTypes map[string]string, prefix string, mapping map[string]interface{}) {
+	properties, _ := mapping["properties"].(map[string]interface{})
+	for name, raw := range properties {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+		fieldType, _ := field["type"].(string)
+		if stringutils.IsEmpty(fieldType) {
+			fieldType = "object"
+		}
+		fieldTypes[path] = fieldType
+		if subFields, ok := field["fields"].(map[string]interface{}); ok {
+			for subName, subRaw := range subFields {
+				if sub, ok := subRaw.(map[string]interface{}); ok {
+					subType, _ := sub["type"].(string)
+					fieldTypes[path+"."+subName] = subType
+				}
+			}
+		}
+		flattenProperties(fieldTypes, path+".", field)
+	}
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func TestNewMapping(t *testing.T) {
-	es := setupSubTest("test_newmapping")
+	es := setupSubTest(t)
 
 	type args struct {
 		mp MappingPayload
@@ -67,7 +67,7 @@ func TestNewMapping(t *testing.T) {
 }
 
 func TestPutMapping(t *testing.T) {
-	es := setupSubTest("test_putmapping")
+	es := setupSubTest(t)
 
 	type args struct {
 		mp MappingPayload
@@ -0,0 +1,119 @@
+package esutils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ErrTimeout is the error type of timeouts and canceled requests
+	ErrTimeout = "timeout"
+	// ErrVersionConflict is the error type of 409 responses
+	ErrVersionConflict = "version_conflict"
+	// ErrClient is the error type of 4xx responses except 409
+	ErrClient = "4xx"
+	// ErrServer is the error type of 5xx responses
+	ErrServer = "5xx"
+	// ErrOther is the error type of other errors like connection refused or invalid arguments
+	ErrOther = "other"
+)
+
+// Metrics collects metrics of Es operations, see package esprom for a Prometheus implementation.
+// Methods are called concurrently
+type Metrics interface {
+	// ObserveOperation is called when operation op on index returns, errType is empty on success or one of ErrTimeout,
+	// ErrVersionConflict, ErrClient, ErrServer and ErrOther
+	ObserveOperation(op, index string, duration time.Duration, errType string)
+	// AddHits is called with number of docs returned by operations like Page and List
+	AddHits(op, index string, hits int)
+	// AddBulkItems is called with number of items indexed or deleted and failed by bulk operations
+	AddBulkItems(op, index string, succeeded, failed int)
+	// AddScrollContexts is called with 1 when a scroll context is opened and -1 when it is cleared
+	AddScrollContexts(index string, delta int)
+}
+
+// WithMetrics collects metrics of Es operations by metrics
+func WithMetrics(metrics Metrics) EsOption {
+	return func(es *Es) {
+		es.metrics = metrics
+	}
+}
+
+// ErrorType classifies err as one of ErrTimeout, ErrVersionConflict, ErrClient, ErrServer and ErrOther,
+// empty string is returned for nil. BulkError is classified by the status of its failed items
+func ErrorType(err error) string {
+	if err == nil {
+		return ""
+	}
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded || cause == context.Canceled {
+		return ErrTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+	var esErr *elastic.Error
+	if errors.As(err, &esErr) {
+		return statusErrorType(esErr.Status)
+	}
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		// a partial failure is a version conflict if any item conflicts, otherwise it is classified by the worst status
+		var status int
+		for _, item := range bulkErr.Failed {
+			if item.Status == http.StatusConflict {
+				return ErrVersionConflict
+			}
+			if item.Status > status {
+				status = item.Status
+			}
+		}
+		return statusErrorType(status)
+	}
+	return ErrOther
+}
+
+func statusErrorType(status int) string {
+	switch {
+	case status == http.StatusConflict:
+		return ErrVersionConflict
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status >= 500:
+		return ErrServer
+	case status >= 400:
+		return ErrClient
+	}
+	return ErrOther
+}
+
+// observe reports op to metrics of e
+func (e *Es) observe(op *operation, elapsed time.Duration, err error) {
+	if e.metrics == nil {
+		return
+	}
+	e.metrics.ObserveOperation(op.name, e.esIndex, elapsed, ErrorType(err))
+	if op.hits >= 0 {
+		e.metrics.AddHits(op.name, e.esIndex, op.hits)
+	}
+	if op.bulkItems > 0 {
+		e.metrics.AddBulkItems(op.name, e.esIndex, op.bulkItems-op.bulkFailed, op.bulkFailed)
+	}
+}
+
+// scrollOpened reports a scroll context of e is opened, the returned function reports it is cleared
+func (e *Es) scrollOpened() func() {
+	if e.metrics == nil {
+		return func() {}
+	}
+	e.metrics.AddScrollContexts(e.esIndex, 1)
+	return func() {
+		e.metrics.AddScrollContexts(e.esIndex, -1)
+	}
+}
@@ -0,0 +1,124 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"deadline", errors.Wrap(context.DeadlineExceeded, "call Search() error"), esutils.ErrTimeout},
+		{"conflict", errors.Wrap(&elastic.Error{Status: 409}, "call Index() error"), esutils.ErrVersionConflict},
+		{"not found", &elastic.Error{Status: 404}, esutils.ErrClient},
+		{"gateway timeout", &elastic.Error{Status: 504}, esutils.ErrTimeout},
+		{"unavailable", &elastic.Error{Status: 503}, esutils.ErrServer},
+		{"other", errors.New("bulk partially failed"), esutils.ErrOther},
+		{"bulk conflict", errors.Wrap(&esutils.BulkError{Failed: []*elastic.BulkResponseItem{{Status: 400}, {Status: 409}}}, "call BulkSaveOrUpdate() error"), esutils.ErrVersionConflict},
+		{"bulk server", &esutils.BulkError{Failed: []*elastic.BulkResponseItem{{Status: 400}, {Status: 503}}}, esutils.ErrServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, esutils.ErrorType(tt.err))
+		})
+	}
+}
+
+// recordMetrics records calls of Metrics
+type recordMetrics struct {
+	mu         sync.Mutex
+	operations []string
+	hits       map[string]int
+	succeeded  int
+	failed     int
+	scrolls    int
+	maxScrolls int
+}
+
+func (m *recordMetrics) ObserveOperation(op, index string, duration time.Duration, errType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations = append(m.operations, op+":"+index+":"+errType)
+}
+
+func (m *recordMetrics) AddHits(op, index string, hits int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hits == nil {
+		m.hits = make(map[string]int)
+	}
+	m.hits[op] += hits
+}
+
+func (m *recordMetrics) AddBulkItems(op, index string, succeeded, failed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.succeeded += succeeded
+	m.failed += failed
+}
+
+func (m *recordMetrics) AddScrollContexts(index string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scrolls += delta
+	if m.scrolls > m.maxScrolls {
+		m.maxScrolls = m.scrolls
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	handleSearch(server, "test_metrics", esutilstest.SearchResponse(2, map[string]interface{}{"_id": "1"}, map[string]interface{}{"_id": "2"}),
+		map[string]interface{}{"_id": "1"})
+	server.Handle(http.MethodPost, "/test_metrics/_doc/_bulk", esutilstest.BulkResponse("", ""))
+	server.Handle(http.MethodPut, "/test_metrics/_doc/1", esutilstest.ErrorResponse(http.StatusConflict, "version_conflict_engine_exception", "version conflict"))
+
+	metrics := &recordMetrics{}
+	es := esutils.NewEs("test_metrics", esutils.WithUrls([]string{server.URL}), esutils.WithMetrics(metrics))
+	_, err := es.Page(context.Background(), &esutils.Paging{Limit: 2})
+	assert.NoError(t, err)
+	err = es.BulkSaveOrUpdate(context.Background(), []interface{}{
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+	})
+	assert.NoError(t, err)
+	_, err = es.SaveOrUpdate(context.Background(), map[string]interface{}{"id": "1"})
+	assert.Error(t, err)
+	docs, err := es.List(context.Background(), &esutils.Paging{Limit: -1}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	server.Handle(http.MethodPost, "/test_metrics/_doc/_bulk", esutilstest.Response{
+		Body: `{"took":1,"errors":true,"items":[{"index":{"_id":"1","status":409,"error":{"type":"version_conflict_engine_exception","reason":"version conflict"}}}]}`,
+	})
+	err = es.BulkSaveOrUpdate(context.Background(), []interface{}{
+		map[string]interface{}{"id": "1"},
+	})
+	assert.EqualError(t, err, "version conflict")
+
+	assert.Equal(t, []string{
+		"Page:test_metrics:",
+		"BulkSaveOrUpdate:test_metrics:",
+		"SaveOrUpdate:test_metrics:version_conflict",
+		"List:test_metrics:",
+		"BulkSaveOrUpdate:test_metrics:version_conflict",
+	}, metrics.operations)
+	assert.Equal(t, map[string]int{"Page": 2, "List": 1}, metrics.hits)
+	assert.Equal(t, 2, metrics.succeeded)
+	assert.Equal(t, 1, metrics.failed)
+	assert.Equal(t, 0, metrics.scrolls)
+	assert.Equal(t, 1, metrics.maxScrolls)
+}
@@ -8,6 +8,11 @@ import (
 
 // NewIndex creates a new index
 func (es *Es) NewIndex(ctx context.Context, mapping string) (exists bool, err error) {
+	ctx, op, err := es.begin(ctx, "NewIndex", &Call{Write: true, Body: mapping})
+	defer func() { err = op.end(exists, err) }()
+	if err != nil {
+		return false, err
+	}
 	var (
 		res *elastic.IndicesCreateResult
 	)
@@ -19,6 +24,7 @@ func (es *Es) NewIndex(ctx context.Context, mapping string) (exists bool, err er
 		if res, err = es.client.CreateIndex(es.esIndex).IncludeTypeName(true).BodyString(mapping).Do(ctx); err != nil {
 			return false, errors.Wrap(err, "call CreateIndex() error")
 		}
+		es.InvalidateMappingCache()
 		if !res.Acknowledged {
 			return false, errors.Wrap(err, "call CreateIndex() failed")
 		}
@@ -28,6 +34,11 @@ func (es *Es) NewIndex(ctx context.Context, mapping string) (exists bool, err er
 
 // NewIndexOnly creates a new index without settings and mappings
 func (es *Es) NewIndexOnly(ctx context.Context) (exists bool, err error) {
+	ctx, op, err := es.begin(ctx, "NewIndexOnly", &Call{Write: true})
+	defer func() { err = op.end(exists, err) }()
+	if err != nil {
+		return false, err
+	}
 	var (
 		res *elastic.IndicesCreateResult
 	)
@@ -39,6 +50,7 @@ func (es *Es) NewIndexOnly(ctx context.Context) (exists bool, err error) {
 		if res, err = es.client.CreateIndex(es.esIndex).Do(ctx); err != nil {
 			return false, errors.Wrap(err, "call CreateIndex() error")
 		}
+		es.InvalidateMappingCache()
 		if !res.Acknowledged {
 			return false, errors.Wrap(err, "call CreateIndex() failed")
 		}
@@ -6,7 +6,7 @@ import (
 )
 
 func TestNewIndex(t *testing.T) {
-	es := setupSubTest("test_newindex")
+	es := setupSubTest(t)
 
 	type args struct {
 		mapping MappingPayload
@@ -0,0 +1,170 @@
+package esutils
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/olivere/elastic/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operation is a call of an Es method like Page or BulkSaveOrUpdate, it is traced and measured as a whole
+type operation struct {
+	es    *Es
+	name  string
+	start time.Time
+	span  trace.Span
+	// hits is the number of docs returned, -1 if the operation doesn't return docs
+	hits int
+	// bulkItems and bulkFailed are numbers of items of bulk requests
+	bulkItems  int
+	bulkFailed int
+	// tookMs is es took time of the last search or bulk request
+	tookMs int64
+	// request is captured for slow query log, guarded by mu
+	request *capturedRequest
+	mu      sync.Mutex
+	// ctx is the context passed to hooks
+	ctx  context.Context
+	call *Call
+	// hooked is the number of hooks whose Before was called
+	hooked int
+	cancel context.CancelFunc
+}
+
+type operationKey struct{}
+
+// begin starts operation name and calls Before of hooks with call, the returned context carries the operation.
+// Call end with the result and the error of the operation even if begin returns error, which short-circuits the operation
+func (e *Es) begin(ctx context.Context, name string, call *Call) (context.Context, *operation, error) {
+	parent := operationFrom(ctx)
+	ctx, span := e.startSpan(ctx, name)
+	if call == nil {
+		call = &Call{}
+	}
+	call.Operation = name
+	call.Index = e.esIndex
+	if call.Header == nil {
+		call.Header = make(http.Header)
+	}
+	op := &operation{
+		es:     e,
+		name:   name,
+		start:  time.Now(),
+		span:   span,
+		hits:   -1,
+		tookMs: -1,
+		call:   call,
+	}
+	ctx = context.WithValue(ctx, operationKey{}, op)
+	op.ctx = ctx
+	if parent != nil {
+		// nested operations are not hooked but send headers of the parent
+		call.Header = parent.call.Header
+		return ctx, op, nil
+	}
+	if err := op.before(); err != nil {
+		return ctx, op, err
+	}
+	if len(call.Header) > 0 && e.customClient {
+		e.headerWarning.Do(func() {
+			e.logger.Errorf("WithHooks: Call.Header set by hooks isn't sent by the client of WithClient")
+		})
+	}
+	if call.Timeout > 0 {
+		ctx, op.cancel = context.WithTimeout(ctx, call.Timeout)
+	}
+	return ctx, op, nil
+}
+
+// end calls After of hooks with response and err, and returns the error of the operation
+func (op *operation) end(response interface{}, err error) error {
+	err = op.after(response, err)
+	if op.cancel != nil {
+		op.cancel()
+	}
+	elapsed := time.Since(op.start)
+	op.es.observe(op, elapsed, err)
+	op.es.logSlow(op, elapsed)
+	endSpan(op.span, err)
+	return err
+}
+
+// capture keeps request if it is the first request of op with body
+func (op *operation) capture(request *capturedRequest) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.request == nil || (op.request.body == "" && request.body != "") {
+		op.request = request
+	}
+}
+
+func (op *operation) captured() *capturedRequest {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.request
+}
+
+// operationFrom returns the innermost operation of ctx, nil if ctx is not in an operation
+func operationFrom(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return op
+}
+
+// detached returns a context carrying the operation of ctx without its cancellation, e.g. for clearing scroll contexts
+func detached(ctx context.Context) context.Context {
+	if op := operationFrom(ctx); op != nil {
+		return context.WithValue(context.Background(), operationKey{}, op)
+	}
+	return context.Background()
+}
+
+// recordSearch records took time and hits of sr on the operation of ctx
+func recordSearch(ctx context.Context, sr *elastic.SearchResult) {
+	if sr == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		attribute.Int64("esutils.took_ms", sr.TookInMillis),
+	}
+	hits := 0
+	if sr.Hits != nil {
+		hits = len(sr.Hits.Hits)
+		if sr.Hits.TotalHits != nil {
+			attrs = append(attrs, attribute.Int64("esutils.total_hits", sr.Hits.TotalHits.Value))
+		}
+	}
+	recordHits(ctx, hits)
+	if op := operationFrom(ctx); op != nil {
+		op.tookMs = sr.TookInMillis
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
+
+// recordHits records number of docs returned by the operation of ctx
+func recordHits(ctx context.Context, hits int) {
+	if op := operationFrom(ctx); op != nil {
+		op.hits = hits
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("esutils.hits", hits))
+}
+
+// recordBulk records took time, number of items and failed items of res on the operation of ctx
+func recordBulk(ctx context.Context, res *elastic.BulkResponse) {
+	if res == nil {
+		return
+	}
+	if op := operationFrom(ctx); op != nil {
+		op.bulkItems += len(res.Items)
+		op.bulkFailed += len(res.Failed())
+		op.tookMs = int64(res.Took)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int64("esutils.took_ms", int64(res.Took)),
+		attribute.Int("esutils.bulk.items", len(res.Items)),
+		attribute.Int("esutils.bulk.failed", len(res.Failed())),
+	)
+}
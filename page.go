@@ -2,7 +2,6 @@ package esutils
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/olivere/elastic/v7"
 	"github.com/pkg/errors"
 	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
@@ -11,19 +10,31 @@ import (
 
 // PageResult represents result of pagination
 type PageResult struct {
-	Page        int           `json:"page"` // from 1
+	Page        int           `json:"page"` // from 1, 0 if Paging.SearchAfter is set
 	PageSize    int           `json:"page_size"`
 	Total       int           `json:"total"`
 	Docs        []interface{} `json:"docs"`
-	HasNextPage bool          `json:"has_next_page"`
+	HasNextPage bool          `json:"has_next_page"` // true if the page is full when Paging.SearchAfter is set
+	// PitID is the latest point in time id returned by es, pass it to the next request
+	PitID string `json:"pit_id"`
+	// SearchAfter is the sort values of the last hit, pass it to Paging.SearchAfter for the next page
+	SearchAfter []interface{} `json:"search_after"`
+	// Groups is the number of distinct values of Paging.Collapse field, HasNextPage is computed from it if collapsed
+	Groups int `json:"groups"`
+	// Profile is es profile output if Paging.Profile is true
+	Profile map[string]interface{} `json:"profile"`
 }
 
 // Page fetch pagination result
-func (es *Es) Page(ctx context.Context, paging *Paging) (PageResult, error) {
+func (es *Es) Page(ctx context.Context, paging *Paging) (pr PageResult, err error) {
+	ctx, op, err := es.begin(ctx, "Page", &Call{Paging: paging})
+	defer func() { err = op.end(pr, err) }()
+	if err != nil {
+		return PageResult{}, err
+	}
+	paging = op.call.Paging
 	var (
-		err       error
 		boolQuery *elastic.BoolQuery
-		pr        PageResult
 	)
 	if paging == nil {
 		paging = &Paging{
@@ -46,7 +57,9 @@ func (es *Es) Page(ctx context.Context, paging *Paging) (PageResult, error) {
 			return PageResult{}, errors.Wrap(err, "call LoadLocation() error")
 		}
 	}
-	boolQuery = query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, zone)
+	if boolQuery, err = pagingQuery(paging, zone); err != nil {
+		return PageResult{}, errors.Wrap(err, "call pagingQuery() error")
+	}
 	var rets []interface{}
 
 	var searchResult *elastic.SearchResult
@@ -57,35 +70,69 @@ func (es *Es) Page(ctx context.Context, paging *Paging) (PageResult, error) {
 	if len(paging.Excludes) > 0 {
 		fsc = fsc.Exclude(paging.Excludes...)
 	}
-	ss := es.client.Search().Index(es.esIndex).Type(es.esType).Query(boolQuery).FetchSourceContext(fsc)
+	ss := es.newSearchService(paging).Query(scoreQuery(boolQuery, paging.Relevance)).FetchSourceContext(fsc)
 	if paging.Sortby != nil && len(paging.Sortby) > 0 {
 		for _, v := range paging.Sortby {
-			ss = ss.Sort(v.Field, v.Ascending)
+			ss = ss.SortBy(v.sorter())
 		}
 	}
-	if searchResult, err = ss.From(paging.Skip).Size(paging.Limit).Do(ctx); err != nil {
+	if len(paging.SearchAfter) > 0 {
+		ss = ss.SearchAfter(paging.SearchAfter...)
+	} else {
+		ss = ss.From(paging.Skip)
+	}
+	if paging.Collapse != nil {
+		ss = ss.Collapse(paging.Collapse.builder()).Aggregation("groups", paging.Collapse.groupsAgg())
+	}
+	if paging.Profile {
+		ss = ss.Profile(true)
+	}
+	if searchResult, err = ss.Size(paging.Limit).Do(ctx); err != nil {
 		return pr, errors.Wrap(err, "call Search() error")
 	}
+	recordSearch(ctx, searchResult)
+	if searchResult.Profile != nil {
+		pr.Profile = profileToMap(searchResult.Profile)
+	}
 	for _, hit := range searchResult.Hits.Hits {
-		var p map[string]interface{}
-		json.Unmarshal(hit.Source, &p)
-		p["_id"] = hit.Id
+		var p interface{}
+		if p, err = hitToDoc(hit, paging, nil); err != nil {
+			return pr, err
+		}
 		rets = append(rets, p)
 	}
+	if n := len(searchResult.Hits.Hits); n > 0 {
+		pr.SearchAfter = searchResult.Hits.Hits[n-1].Sort
+	}
+	pr.PitID = searchResult.PitId
 
 	pr.Docs = rets
 	pr.Total = int(searchResult.TotalHits())
+	if paging.Collapse != nil {
+		if groups, ok := searchResult.Aggregations.Cardinality("groups"); ok && groups.Value != nil {
+			pr.Groups = int(*groups.Value)
+		}
+	}
 	pr.PageSize = paging.Limit
+	if len(paging.SearchAfter) > 0 {
+		// Skip is ignored in search_after mode, so the page number is unknown
+		pr.HasNextPage = paging.Limit > 0 && len(searchResult.Hits.Hits) == paging.Limit
+		return pr, nil
+	}
 	if paging.Limit > 0 {
 		pr.Page = paging.Skip/paging.Limit + 1
 	}
 
+	total := pr.Total
+	if paging.Collapse != nil {
+		total = pr.Groups
+	}
 	var totalPage int
 	if pr.PageSize > 0 {
-		if pr.Total%pr.PageSize > 0 {
-			totalPage = pr.Total/pr.PageSize + 1
+		if total%pr.PageSize > 0 {
+			totalPage = total/pr.PageSize + 1
 		} else {
-			totalPage = pr.Total / pr.PageSize
+			totalPage = total / pr.PageSize
 		}
 	}
 
@@ -3,11 +3,12 @@ package esutils
 import (
 	"context"
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestPage(t *testing.T) {
-	es := setupSubTest("test_page")
+	es := setupSubTest(t)
 
 	type args struct {
 		paging *Paging
@@ -82,3 +83,209 @@ func TestPage(t *testing.T) {
 		})
 	}
 }
+
+func TestPage_nested(t *testing.T) {
+	es := setupIndex(t, `{"mappings":{"_doc":{"properties":{"line":{"type":"nested","properties":{"sku":{"type":"keyword"},"qty":{"type":"integer"}}}}}}}`,
+		map[string]interface{}{
+			"id": "1",
+			"line": []interface{}{
+				map[string]interface{}{"sku": "A001", "qty": 1},
+				map[string]interface{}{"sku": "B001", "qty": 5},
+			},
+		},
+		map[string]interface{}{
+			"id": "2",
+			"line": []interface{}{
+				map[string]interface{}{"sku": "A001", "qty": 5},
+			},
+		},
+	)
+	got, err := es.Page(context.Background(), &Paging{
+		Limit: 10,
+		QueryConds: []QueryCond{
+			{
+				QueryLogic: MUST,
+				QueryType:  NESTED,
+				Path:       "line",
+				InnerHits:  &InnerHits{},
+				Children: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"line.sku": {"A001"},
+						},
+						QueryLogic: MUST,
+						QueryType:  TERMS,
+					},
+					{
+						Pair: map[string][]interface{}{
+							"line.qty": {map[string]interface{}{
+								"from": 2,
+							}},
+						},
+						QueryLogic: MUST,
+						QueryType:  RANGE,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, got.Total)
+	doc := got.Docs[0].(map[string]interface{})
+	assert.Equal(t, "2", doc["_id"])
+	assert.Equal(t, map[string]interface{}{
+		"line": []interface{}{
+			map[string]interface{}{"sku": "A001", "qty": float64(5)},
+		},
+	}, doc["_inner_hits"])
+}
+
+func TestPage_geo(t *testing.T) {
+	es := setupIndex(t, NewMapping(MappingPayload{
+		Fields: []Field{
+			{
+				Name: "location",
+				Type: GEOPOINT,
+			},
+			{
+				Name: "name",
+				Type: KEYWORD,
+			},
+		},
+	}),
+		map[string]interface{}{
+			"id":       "1",
+			"name":     "Tiananmen",
+			"location": map[string]interface{}{"lat": 39.9087, "lon": 116.3975},
+		},
+		map[string]interface{}{
+			"id":       "2",
+			"name":     "Summer Palace",
+			"location": map[string]interface{}{"lat": 39.9999, "lon": 116.2755},
+		},
+		map[string]interface{}{
+			"id":       "3",
+			"name":     "Bund",
+			"location": map[string]interface{}{"lat": 31.2400, "lon": 121.4900},
+		},
+	)
+	got, err := es.Page(context.Background(), &Paging{
+		Limit: 10,
+		QueryConds: []QueryCond{
+			{
+				Pair: map[string][]interface{}{
+					"location": {map[string]interface{}{
+						"lat":      39.9042,
+						"lon":      116.4074,
+						"distance": "50km",
+					}},
+				},
+				QueryLogic: MUST,
+				QueryType:  GEODISTANCE,
+			},
+		},
+		Sortby: []Sort{
+			{
+				Field:     "location",
+				Ascending: true,
+				GeoPoint:  &GeoPoint{Lat: 39.9042, Lon: 116.4074},
+				Unit:      "km",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, got.Total)
+	first := got.Docs[0].(map[string]interface{})
+	second := got.Docs[1].(map[string]interface{})
+	assert.Equal(t, "1", first["_id"])
+	assert.Equal(t, "2", second["_id"])
+	assert.Less(t, first["_distance"].(float64), second["_distance"].(float64))
+}
+
+func TestPage_relevance(t *testing.T) {
+	es := setupSubTest(t)
+	got, err := es.Page(context.Background(), &Paging{
+		Limit: 10,
+		Relevance: &Relevance{
+			Functions: []ScoreFunction{
+				{
+					Filter: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"type.keyword": {"sport"},
+							},
+							QueryLogic: MUST,
+							QueryType:  TERMS,
+						},
+					},
+					Weight: 10,
+				},
+				{
+					Decay: &Decay{
+						Field:  "createAt",
+						Origin: "2020-07-10",
+						Scale:  "10d",
+					},
+				},
+			},
+			ScoreMode: "sum",
+			BoostMode: "replace",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, got.Total)
+	var ids []interface{}
+	for _, doc := range got.Docs {
+		ids = append(ids, doc.(map[string]interface{})["_id"])
+	}
+	assert.Equal(t, []interface{}{"9seTXHoBNx091WJ2QCh6", "9seTXHoBNx091WJ2QCh7", "9seTXHoBNx091WJ2QCh5"}, ids)
+}
+
+func TestPage_collapse(t *testing.T) {
+	es := setupIndex(t, `{"mappings":{"_doc":{"properties":{"product":{"type":"keyword"},"color":{"type":"keyword"},"price":{"type":"integer"}}}}}`,
+		map[string]interface{}{"id": "1", "product": "iphone", "color": "black", "price": 999},
+		map[string]interface{}{"id": "2", "product": "iphone", "color": "white", "price": 899},
+		map[string]interface{}{"id": "3", "product": "ipad", "color": "silver", "price": 599},
+		map[string]interface{}{"id": "4", "product": "ipad", "color": "gray", "price": 499},
+		map[string]interface{}{"id": "5", "product": "mac", "color": "silver", "price": 1299},
+	)
+	got, err := es.Page(context.Background(), &Paging{
+		Limit: 2,
+		Sortby: []Sort{
+			{
+				Field: "price",
+			},
+		},
+		Collapse: &Collapse{
+			Field: "product",
+			InnerHits: &InnerHits{
+				Size: 5,
+				Sortby: []Sort{
+					{
+						Field:     "price",
+						Ascending: true,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, got.Groups)
+	assert.True(t, got.HasNextPage)
+	assert.Len(t, got.Docs, 2)
+	doc := got.Docs[0].(map[string]interface{})
+	assert.Equal(t, "5", doc["_id"])
+	doc = got.Docs[1].(map[string]interface{})
+	assert.Equal(t, "1", doc["_id"])
+	variants := doc["_inner_hits"].(map[string]interface{})["product"].([]interface{})
+	assert.Len(t, variants, 2)
+	assert.Equal(t, "white", variants[0].(map[string]interface{})["color"])
+}
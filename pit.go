@@ -0,0 +1,98 @@
+package esutils
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// OpenPIT opens a point in time on the index and returns its id, keepAlive defaults to 1m.
+// Pass the id to Paging.PitID so that Page and List read from a consistent snapshot,
+// and call ClosePIT when finished.
+func (es *Es) OpenPIT(ctx context.Context, keepAlive string) (pitID string, err error) {
+	ctx, op, err := es.begin(ctx, "OpenPIT", nil)
+	defer func() { err = op.end(pitID, err) }()
+	if err != nil {
+		return "", err
+	}
+	var (
+		res *elastic.OpenPointInTimeResponse
+	)
+	if stringutils.IsEmpty(keepAlive) {
+		keepAlive = "1m"
+	}
+	if res, err = es.client.OpenPointInTime(es.esIndex).KeepAlive(keepAlive).Do(ctx); err != nil {
+		return "", errors.Wrap(err, "call OpenPointInTime() error")
+	}
+	return res.Id, nil
+}
+
+// ClosePIT closes the point in time, closing an expired one is not an error
+func (es *Es) ClosePIT(ctx context.Context, pitID string) (err error) {
+	ctx, op, err := es.begin(ctx, "ClosePIT", nil)
+	defer func() { err = op.end(nil, err) }()
+	if err != nil {
+		return err
+	}
+	var (
+		res *elastic.ClosePointInTimeResponse
+	)
+	if res, err = es.client.ClosePointInTime(pitID).Do(ctx); err != nil {
+		if elastic.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "call ClosePointInTime() error")
+	}
+	if !res.Succeeded {
+		return errors.New("failed to close point in time " + pitID)
+	}
+	return nil
+}
+
+// fetchAllPIT fetches all docs from the point in time page by page with search_after
+func (es *Es) fetchAllPIT(ctx context.Context, fsc *elastic.FetchSourceContext, paging *Paging, esQuery elastic.Query, callback func(message json.RawMessage) (interface{}, error)) ([]interface{}, error) {
+	var (
+		rets         []interface{}
+		searchResult *elastic.SearchResult
+		err          error
+	)
+	p := *paging
+	size := p.ScrollSize
+	if size <= 0 {
+		size = 1000
+	}
+	for {
+		ss := es.newSearchService(&p).Query(esQuery).FetchSourceContext(fsc).Size(size)
+		if len(p.Sortby) > 0 {
+			for _, v := range p.Sortby {
+				ss = ss.SortBy(v.sorter())
+			}
+		} else {
+			ss = ss.Sort("_shard_doc", true)
+		}
+		if len(p.SearchAfter) > 0 {
+			ss = ss.SearchAfter(p.SearchAfter...)
+		}
+		if searchResult, err = ss.Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "call Search() error")
+		}
+		hits := searchResult.Hits.Hits
+		for _, hit := range hits {
+			var ret interface{}
+			if ret, err = hitToDoc(hit, &p, callback); err != nil {
+				return nil, err
+			}
+			rets = append(rets, ret)
+		}
+		if len(hits) < size {
+			break
+		}
+		p.SearchAfter = hits[len(hits)-1].Sort
+		if stringutils.IsNotEmpty(searchResult.PitId) {
+			p.PitID = searchResult.PitId
+		}
+	}
+	return rets, nil
+}
@@ -0,0 +1,65 @@
+package esutils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEs_PIT(t *testing.T) {
+	es := setupSubTest(t)
+	pitID, err := es.OpenPIT(context.Background(), "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer es.ClosePIT(context.Background(), pitID)
+
+	// writes after the point in time was opened are invisible to it
+	_, err = es.SaveOrUpdate(context.Background(), map[string]interface{}{
+		"id":       "9seTXHoBNx091WJ2QCh8",
+		"createAt": "2020-07-11T00:00:00Z",
+		"type":     "sport",
+		"text":     "new doc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paging := &Paging{
+		PitID: pitID,
+		Limit: 2,
+		Sortby: []Sort{
+			{
+				Field:     "createAt",
+				Ascending: true,
+			},
+		},
+	}
+	pr, err := es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pr.Docs, 2)
+	assert.NotEmpty(t, pr.SearchAfter)
+
+	paging.PitID = pr.PitID
+	paging.SearchAfter = pr.SearchAfter
+	pr, err = es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pr.Docs, 1)
+	assert.Equal(t, "9seTXHoBNx091WJ2QCh7", pr.Docs[0].(map[string]interface{})["_id"])
+
+	docs, err := es.List(context.Background(), &Paging{
+		PitID:      pitID,
+		Limit:      -1,
+		ScrollSize: 1,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, docs, 3)
+
+	assert.NoError(t, es.ClosePIT(context.Background(), pitID))
+}
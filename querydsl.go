@@ -0,0 +1,809 @@
+package esutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// QueryDSLError reports a query dsl construct which cannot be represented by QueryCond
+type QueryDSLError struct {
+	// Path locates the construct in the json, e.g. query.bool.must[1].range
+	Path string `json:"path"`
+	Msg  string `json:"msg"`
+}
+
+func (e *QueryDSLError) Error() string {
+	return fmt.Sprintf("query dsl error at %s: %s", e.Path, e.Msg)
+}
+
+var boolClauses = []struct {
+	key   string
+	logic queryLogic
+}{
+	{"must", MUST},
+	{"filter", FILTER},
+	{"should", SHOULD},
+	{"must_not", MUSTNOT},
+}
+
+// ParseQueryDSL converts elasticsearch query dsl json, such as saved searches from Kibana, into QueryConds for Paging.QueryConds.
+// Both {"query": {...}} and bare query objects are accepted.
+// Supported query types are bool, terms, term, match_phrase, range, prefix, wildcard, regexp, fuzzy, ids, exists,
+// nested, geo_distance, geo_bounding_box, geo_polygon, bool_prefix multi_match and match_all,
+// others are rejected with a *QueryDSLError pointing to the offending construct.
+func ParseQueryDSL(dsl []byte) ([]QueryCond, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(dsl, &root); err != nil {
+		return nil, &QueryDSLError{Path: "$", Msg: err.Error()}
+	}
+	path := "$"
+	if q, ok := root["query"]; ok && len(root) == 1 {
+		m, ok := q.(map[string]interface{})
+		if !ok {
+			return nil, &QueryDSLError{Path: "query", Msg: "query must be an object"}
+		}
+		root, path = m, "query"
+	}
+	if len(root) == 0 {
+		return nil, nil
+	}
+	typ, body, err := singleKey(root, path)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case "match_all":
+		return nil, nil
+	case "bool":
+		b, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, &QueryDSLError{Path: path + ".bool", Msg: "bool query must be an object"}
+		}
+		return parseRootBool(b, path+".bool")
+	}
+	qc, err := parseDSLQuery(root, MUST, path)
+	if err != nil {
+		return nil, err
+	}
+	return []QueryCond{qc}, nil
+}
+
+// parseRootBool flattens the root bool query into QueryConds, query() sets minimum_should_match to 1 on the root
+// by default whenever there is a should condition, so a root bool with optional should clauses, other minimum_should_match
+// or boost is wrapped as a child
+func parseRootBool(b map[string]interface{}, path string) ([]QueryCond, error) {
+	var conds []QueryCond
+	var hasShould, hasOthers bool
+	for _, clause := range boolClauses {
+		queries, err := clauseQueries(b, clause.key, path)
+		if err != nil {
+			return nil, err
+		}
+		for i, q := range queries {
+			qc, err := parseDSLQuery(q, clause.logic, fmt.Sprintf("%s.%s[%d]", path, clause.key, i))
+			if err != nil {
+				return nil, err
+			}
+			if qc.QueryLogic == 0 {
+				continue
+			}
+			if clause.logic == SHOULD {
+				hasShould = true
+			} else {
+				hasOthers = true
+			}
+			conds = append(conds, qc)
+		}
+	}
+	msm, boost, err := boolOptions(b, path)
+	if err != nil {
+		return nil, err
+	}
+	wrap := boost > 0
+	if hasShould && stringutils.IsEmpty(msm) {
+		wrap = wrap || hasOthers
+	} else if hasShould {
+		wrap = wrap || msm != "1"
+	}
+	if wrap {
+		return []QueryCond{
+			{
+				QueryLogic:         MUST,
+				Children:           conds,
+				MinimumShouldMatch: msm,
+				Boost:              boost,
+			},
+		}, nil
+	}
+	return conds, nil
+}
+
+// boolOptions returns minimum_should_match and boost of the bool query and rejects other options
+func boolOptions(b map[string]interface{}, path string) (string, float64, error) {
+	var (
+		msm   string
+		boost float64
+	)
+	for key, value := range b {
+		switch key {
+		case "must", "filter", "should", "must_not":
+		case "minimum_should_match":
+			switch value.(type) {
+			case string, float64:
+				msm = fmt.Sprint(value)
+			default:
+				return "", 0, &QueryDSLError{Path: path + "." + key, Msg: "minimum_should_match must be a number or a string"}
+			}
+		case "boost":
+			n, ok := value.(float64)
+			if !ok {
+				return "", 0, &QueryDSLError{Path: path + "." + key, Msg: "boost must be a number"}
+			}
+			boost = n
+		default:
+			return "", 0, &QueryDSLError{Path: path + "." + key, Msg: fmt.Sprintf("unsupported bool option %q", key)}
+		}
+	}
+	return msm, boost, nil
+}
+
+// clauseQueries returns the queries of the bool clause which may be a single object or an array
+func clauseQueries(b map[string]interface{}, key, path string) ([]map[string]interface{}, error) {
+	raw, ok := b[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	if m, ok := raw.(map[string]interface{}); ok {
+		return []map[string]interface{}{m}, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, &QueryDSLError{Path: path + "." + key, Msg: "clause must be an object or an array"}
+	}
+	var queries []map[string]interface{}
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, &QueryDSLError{Path: fmt.Sprintf("%s.%s[%d]", path, key, i), Msg: "query must be an object"}
+		}
+		queries = append(queries, m)
+	}
+	return queries, nil
+}
+
+func singleKey(m map[string]interface{}, path string) (string, interface{}, error) {
+	if len(m) != 1 {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return "", nil, &QueryDSLError{Path: path, Msg: fmt.Sprintf("expected exactly one key, got %v", keys)}
+	}
+	for k, v := range m {
+		return k, v, nil
+	}
+	return "", nil, nil
+}
+
+// fieldParams returns the only field of a leaf query like {"prefix": {"name": {...}}}
+func fieldParams(body interface{}, path string) (string, interface{}, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return "", nil, &QueryDSLError{Path: path, Msg: "query must be an object"}
+	}
+	return singleKey(m, path)
+}
+
+// valueParam returns the value of the field, which is either the value itself or an object holding the value under key
+func valueParam(params interface{}, key, path string, allowed ...string) (interface{}, error) {
+	m, ok := params.(map[string]interface{})
+	if !ok {
+		return params, nil
+	}
+	for k := range m {
+		if k == key {
+			continue
+		}
+		var found bool
+		for _, a := range allowed {
+			if k == a {
+				found = true
+			}
+		}
+		if !found {
+			return nil, &QueryDSLError{Path: path + "." + k, Msg: fmt.Sprintf("unsupported option %q", k)}
+		}
+	}
+	v, ok := m[key]
+	if !ok {
+		return nil, &QueryDSLError{Path: path, Msg: fmt.Sprintf("missing %q", key)}
+	}
+	return v, nil
+}
+
+var shingleSubfield = regexp.MustCompile(`\._([234])gram$`)
+
+// boolPrefixField returns the search_as_you_type field of bool_prefix multi_match query and the max shingle size of
+// its subfields, 0 if there is none. fields must be the field and its shingle subfields
+func boolPrefixField(fields interface{}, path string) (string, int, error) {
+	items, ok := fields.([]interface{})
+	if !ok || len(items) == 0 {
+		return "", 0, &QueryDSLError{Path: path, Msg: "fields must be a non-empty array"}
+	}
+	var (
+		field          string
+		maxShingleSize int
+	)
+	for _, item := range items {
+		name, ok := item.(string)
+		if !ok {
+			return "", 0, &QueryDSLError{Path: path, Msg: fmt.Sprintf("field must be a string, got %T", item)}
+		}
+		if m := shingleSubfield.FindStringSubmatch(name); m != nil {
+			if n := int(m[1][0] - '0'); n > maxShingleSize {
+				maxShingleSize = n
+			}
+			name = strings.TrimSuffix(name, m[0])
+		}
+		if stringutils.IsNotEmpty(field) && field != name {
+			return "", 0, &QueryDSLError{Path: path, Msg: "fields must be a search_as_you_type field and its shingle subfields"}
+		}
+		field = name
+	}
+	return field, maxShingleSize, nil
+}
+
+// copyWithout returns a shallow copy of m without key
+func copyWithout(m map[string]interface{}, key string) map[string]interface{} {
+	ret := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != key {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// valueOptions returns options of the field if they are given as an object
+func valueOptions(params interface{}) map[string]interface{} {
+	m, _ := params.(map[string]interface{})
+	return m
+}
+
+// parseDSLQuery converts a single query joined to its parent by logic, the returned QueryCond has zero QueryLogic
+// if the query matches everything and can be dropped
+func parseDSLQuery(q map[string]interface{}, logic queryLogic, path string) (QueryCond, error) {
+	typ, body, err := singleKey(q, path)
+	if err != nil {
+		return QueryCond{}, err
+	}
+	path = path + "." + typ
+	leaf := func(field string, qt queryType, values ...interface{}) QueryCond {
+		if values == nil {
+			values = []interface{}{}
+		}
+		return QueryCond{
+			Pair: map[string][]interface{}{
+				field: values,
+			},
+			QueryLogic: logic,
+			QueryType:  qt,
+		}
+	}
+	switch typ {
+	case "match_all":
+		if logic != MUST && logic != FILTER {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "match_all is only supported in must or filter clauses"}
+		}
+		return QueryCond{}, nil
+	case "bool":
+		b, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "bool query must be an object"}
+		}
+		if field, values, ok := phraseGroup(b); ok {
+			qc := leaf(field, MATCHPHRASE, values...)
+			qc.Boost, _ = b["boost"].(float64)
+			return qc, nil
+		}
+		qc := QueryCond{
+			QueryLogic: logic,
+		}
+		for _, clause := range boolClauses {
+			queries, err := clauseQueries(b, clause.key, path)
+			if err != nil {
+				return QueryCond{}, err
+			}
+			for i, child := range queries {
+				cqc, err := parseDSLQuery(child, clause.logic, fmt.Sprintf("%s.%s[%d]", path, clause.key, i))
+				if err != nil {
+					return QueryCond{}, err
+				}
+				if cqc.QueryLogic == 0 {
+					continue
+				}
+				qc.Children = append(qc.Children, cqc)
+			}
+		}
+		if qc.MinimumShouldMatch, qc.Boost, err = boolOptions(b, path); err != nil {
+			return QueryCond{}, err
+		}
+		if len(qc.Children) == 0 {
+			if logic != MUST && logic != FILTER {
+				return QueryCond{}, &QueryDSLError{Path: path, Msg: "empty bool query is only supported in must or filter clauses"}
+			}
+			return QueryCond{}, nil
+		}
+		return qc, nil
+	case "terms":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "query must be an object"}
+		}
+		boost, hasBoost := m["boost"].(float64)
+		if hasBoost {
+			m = copyWithout(m, "boost")
+		}
+		field, values, err := fieldParams(m, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		items, ok := values.([]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: "terms query only supports an array of values"}
+		}
+		if len(items) == 0 {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: "terms query must have at least one value"}
+		}
+		qc := leaf(field, TERMS, items...)
+		qc.Boost = boost
+		return qc, nil
+	case "term":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		value, err := valueParam(params, "value", path+"."+field, "case_insensitive", "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		qc := leaf(field, TERM, value)
+		qc.CaseInsensitive, _ = valueOptions(params)["case_insensitive"].(bool)
+		qc.Boost, _ = valueOptions(params)["boost"].(float64)
+		return qc, nil
+	case "fuzzy":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		value, err := valueParam(params, "value", path+"."+field, "fuzziness", "prefix_length", "max_expansions", "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		qc := leaf(field, FUZZY, value)
+		opts := valueOptions(params)
+		if opts["fuzziness"] != nil {
+			qc.Fuzziness = fmt.Sprint(opts["fuzziness"])
+		}
+		if n, ok := opts["prefix_length"].(float64); ok {
+			qc.PrefixLength = int(n)
+		}
+		if n, ok := opts["max_expansions"].(float64); ok {
+			qc.MaxExpansions = int(n)
+		}
+		qc.Boost, _ = opts["boost"].(float64)
+		return qc, nil
+	case "regexp":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		value, err := valueParam(params, "value", path+"."+field, "flags", "case_insensitive", "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		qc := leaf(field, REGEXP, value)
+		opts := valueOptions(params)
+		qc.Flags, _ = opts["flags"].(string)
+		qc.CaseInsensitive, _ = opts["case_insensitive"].(bool)
+		qc.Boost, _ = opts["boost"].(float64)
+		return qc, nil
+	case "ids":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "ids query must be an object"}
+		}
+		values, err := valueParam(m, "values", path, "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		items, ok := values.([]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + ".values", Msg: "values must be an array"}
+		}
+		qc := leaf("_id", IDS, items...)
+		qc.Boost, _ = m["boost"].(float64)
+		return qc, nil
+	case "multi_match":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "multi_match query must be an object"}
+		}
+		value, err := valueParam(m, "query", path, "type", "fields", "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		text, ok := value.(string)
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + ".query", Msg: "multi_match query must be a string"}
+		}
+		if m["type"] != "bool_prefix" {
+			return QueryCond{}, &QueryDSLError{Path: path + ".type", Msg: "only bool_prefix multi_match query is supported"}
+		}
+		field, maxShingleSize, err := boolPrefixField(m["fields"], path+".fields")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		qc := leaf(field, BOOLPREFIX, text)
+		qc.Boost, _ = m["boost"].(float64)
+		if maxShingleSize != 3 {
+			qc.MaxShingleSize = maxShingleSize
+		}
+		return qc, nil
+	case "match_phrase":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		value, err := valueParam(params, "query", path+"."+field, "boost")
+		if err != nil {
+			return QueryCond{}, err
+		}
+		phrase, ok := value.(string)
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: "match_phrase query must be a string"}
+		}
+		if strings.Contains(phrase, "+") || strings.HasPrefix(strings.TrimSpace(phrase), "-") {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: fmt.Sprintf("phrase %q must not contain \"+\" or start with \"-\"", phrase)}
+		}
+		qc := leaf(field, MATCHPHRASE, phrase)
+		qc.Boost, _ = valueOptions(params)["boost"].(float64)
+		return qc, nil
+	case "range":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		paramsMap, err := rangeParams(params, path+"."+field)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		qc := leaf(field, RANGE, paramsMap)
+		qc.Boost, _ = valueOptions(params)["boost"].(float64)
+		return qc, nil
+	case "prefix", "wildcard":
+		field, params, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		var value interface{}
+		if typ == "wildcard" && valueOptions(params)["wildcard"] != nil {
+			value, err = valueParam(params, "wildcard", path+"."+field, "case_insensitive", "boost")
+		} else {
+			value, err = valueParam(params, "value", path+"."+field, "case_insensitive", "boost")
+		}
+		if err != nil {
+			return QueryCond{}, err
+		}
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: typ + " query must be a non-empty string"}
+		}
+		qc := leaf(field, WILDCARD, s)
+		if typ == "prefix" {
+			qc.QueryType = PREFIX
+		}
+		qc.CaseInsensitive, _ = valueOptions(params)["case_insensitive"].(bool)
+		qc.Boost, _ = valueOptions(params)["boost"].(float64)
+		return qc, nil
+	case "nested":
+		return parseNested(body, logic, path)
+	case "geo_distance":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "geo_distance query must be an object"}
+		}
+		params := make(map[string]interface{})
+		var field string
+		for k, v := range m {
+			switch k {
+			case "distance", "distance_type":
+				s, ok := v.(string)
+				if !ok {
+					return QueryCond{}, &QueryDSLError{Path: path + "." + k, Msg: k + " must be a string"}
+				}
+				params[k] = s
+			case "_name", "validation_method", "ignore_unmapped", "boost":
+				return QueryCond{}, &QueryDSLError{Path: path + "." + k, Msg: fmt.Sprintf("unsupported geo_distance option %q", k)}
+			default:
+				if field != "" {
+					return QueryCond{}, &QueryDSLError{Path: path + "." + k, Msg: "geo_distance query must have only one field"}
+				}
+				point, ok := toGeoPoint(v)
+				if !ok {
+					return QueryCond{}, &QueryDSLError{Path: path + "." + k, Msg: "unsupported geo point"}
+				}
+				field = k
+				params["lat"], params["lon"] = point.Lat, point.Lon
+			}
+		}
+		if field == "" || params["distance"] == nil {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "geo_distance query must have a field and distance"}
+		}
+		return leaf(field, GEODISTANCE, params), nil
+	case "geo_bounding_box":
+		field, box, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		m, ok := box.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: "bounding box must be an object"}
+		}
+		params := make(map[string]interface{})
+		for k, v := range m {
+			if k != "top_left" && k != "bottom_right" {
+				return QueryCond{}, &QueryDSLError{Path: path + "." + field + "." + k, Msg: fmt.Sprintf("unsupported bounding box option %q, only top_left and bottom_right are supported", k)}
+			}
+			point, ok := toGeoPoint(v)
+			if !ok {
+				return QueryCond{}, &QueryDSLError{Path: path + "." + field + "." + k, Msg: "unsupported geo point"}
+			}
+			params[k] = map[string]interface{}{"lat": point.Lat, "lon": point.Lon}
+		}
+		if len(params) != 2 {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field, Msg: "bounding box must have top_left and bottom_right"}
+		}
+		return leaf(field, GEOBOUNDINGBOX, params), nil
+	case "geo_polygon":
+		field, polygon, err := fieldParams(body, path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		points, err := valueParam(polygon, "points", path+"."+field)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		items, ok := points.([]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path + "." + field + ".points", Msg: "points must be an array"}
+		}
+		var values []interface{}
+		for i, item := range items {
+			point, ok := toGeoPoint(item)
+			if !ok {
+				return QueryCond{}, &QueryDSLError{Path: fmt.Sprintf("%s.%s.points[%d]", path, field, i), Msg: "unsupported geo point"}
+			}
+			values = append(values, map[string]interface{}{"lat": point.Lat, "lon": point.Lon})
+		}
+		return leaf(field, GEOPOLYGON, values...), nil
+	case "exists":
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return QueryCond{}, &QueryDSLError{Path: path, Msg: "exists query must be an object"}
+		}
+		field, err := valueParam(m, "field", path)
+		if err != nil {
+			return QueryCond{}, err
+		}
+		s, ok := field.(string)
+		if !ok || s == "" {
+			return QueryCond{}, &QueryDSLError{Path: path + ".field", Msg: "field must be a non-empty string"}
+		}
+		return leaf(s, EXISTS), nil
+	}
+	return QueryCond{}, &QueryDSLError{Path: path, Msg: fmt.Sprintf("unsupported query type %q", typ)}
+}
+
+// parseNested converts nested query to NESTED QueryCond whose Children are the clauses of the inner bool query
+func parseNested(body interface{}, logic queryLogic, path string) (QueryCond, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return QueryCond{}, &QueryDSLError{Path: path, Msg: "nested query must be an object"}
+	}
+	qc := QueryCond{
+		QueryLogic: logic,
+		QueryType:  NESTED,
+	}
+	for k, v := range m {
+		switch k {
+		case "path":
+			qc.Path, _ = v.(string)
+		case "score_mode":
+			qc.ScoreMode, _ = v.(string)
+		case "boost":
+			n, ok := v.(float64)
+			if !ok {
+				return QueryCond{}, &QueryDSLError{Path: path + ".boost", Msg: "boost must be a number"}
+			}
+			qc.Boost = n
+		case "query":
+			q, ok := v.(map[string]interface{})
+			if !ok {
+				return QueryCond{}, &QueryDSLError{Path: path + ".query", Msg: "query must be an object"}
+			}
+			child, err := parseDSLQuery(q, MUST, path+".query")
+			if err != nil {
+				return QueryCond{}, err
+			}
+			if child.QueryLogic == 0 {
+				continue
+			}
+			if child.QueryType == 0 && len(child.Children) > 0 && child.Boost == 0 {
+				qc.Children = child.Children
+				qc.MinimumShouldMatch = child.MinimumShouldMatch
+			} else {
+				qc.Children = []QueryCond{child}
+			}
+		case "inner_hits":
+			ih, ok := v.(map[string]interface{})
+			if !ok {
+				return QueryCond{}, &QueryDSLError{Path: path + ".inner_hits", Msg: "inner_hits must be an object"}
+			}
+			qc.InnerHits = &InnerHits{}
+			for ik, iv := range ih {
+				switch ik {
+				case "name":
+					qc.InnerHits.Name, _ = iv.(string)
+				case "from", "size":
+					n, ok := iv.(float64)
+					if !ok {
+						return QueryCond{}, &QueryDSLError{Path: path + ".inner_hits." + ik, Msg: ik + " must be a number"}
+					}
+					if ik == "from" {
+						qc.InnerHits.From = int(n)
+					} else {
+						qc.InnerHits.Size = int(n)
+					}
+				default:
+					return QueryCond{}, &QueryDSLError{Path: path + ".inner_hits." + ik, Msg: fmt.Sprintf("unsupported inner_hits option %q", ik)}
+				}
+			}
+		default:
+			return QueryCond{}, &QueryDSLError{Path: path + "." + k, Msg: fmt.Sprintf("unsupported nested option %q", k)}
+		}
+	}
+	if qc.Path == "" {
+		return QueryCond{}, &QueryDSLError{Path: path + ".path", Msg: "nested query must have a path"}
+	}
+	return qc, nil
+}
+
+// rangeParams converts gt, gte, lt, lte, from, to, include_lower and include_upper to the params map of RANGE query
+func rangeParams(params interface{}, path string) (map[string]interface{}, error) {
+	m, ok := params.(map[string]interface{})
+	if !ok {
+		return nil, &QueryDSLError{Path: path, Msg: "range query must be an object"}
+	}
+	ret := make(map[string]interface{})
+	for k, v := range m {
+		switch k {
+		case "gt", "gte":
+			if v != nil {
+				ret["from"] = v
+				ret["include_lower"] = k == "gte"
+			}
+		case "lt", "lte":
+			if v != nil {
+				ret["to"] = v
+				ret["include_upper"] = k == "lte"
+			}
+		case "from", "to":
+			if v != nil {
+				ret[k] = v
+			}
+		case "boost":
+		case "include_lower", "include_upper":
+			b, ok := v.(bool)
+			if !ok {
+				return nil, &QueryDSLError{Path: path + "." + k, Msg: k + " must be a boolean"}
+			}
+			if _, set := ret[k]; !set {
+				ret[k] = b
+			}
+		default:
+			return nil, &QueryDSLError{Path: path + "." + k, Msg: fmt.Sprintf("unsupported range option %q", k)}
+		}
+	}
+	if ret["from"] == nil && ret["to"] == nil {
+		return nil, &QueryDSLError{Path: path, Msg: "range query must have at least one bound"}
+	}
+	return ret, nil
+}
+
+// phraseGroup recognizes the bool query built by matchPhrase, whose should clauses are match_phrase queries on one field,
+// or bool queries of match_phrase queries combined by must and must_not which are written as a+b+-c in MATCHPHRASE values
+func phraseGroup(b map[string]interface{}) (string, []interface{}, bool) {
+	if _, ok := b["boost"].(float64); ok {
+		b = copyWithout(b, "boost")
+	}
+	if len(b) != 1 {
+		return "", nil, false
+	}
+	shoulds, err := clauseQueries(b, "should", "")
+	if err != nil || len(shoulds) == 0 {
+		return "", nil, false
+	}
+	var (
+		field  string
+		values []interface{}
+	)
+	phrase := func(q map[string]interface{}) (string, bool) {
+		mp, ok := q["match_phrase"].(map[string]interface{})
+		if !ok || len(q) != 1 || len(mp) != 1 {
+			return "", false
+		}
+		for f, params := range mp {
+			if field != "" && f != field {
+				return "", false
+			}
+			field = f
+			value, err := valueParam(params, "query", "")
+			if err != nil {
+				return "", false
+			}
+			s, ok := value.(string)
+			if !ok || s == "" || strings.Contains(s, "+") || strings.HasPrefix(s, "-") {
+				return "", false
+			}
+			return s, true
+		}
+		return "", false
+	}
+	for _, q := range shoulds {
+		if word, ok := phrase(q); ok {
+			values = append(values, word)
+			continue
+		}
+		nested, ok := q["bool"].(map[string]interface{})
+		if !ok || len(q) != 1 {
+			return "", nil, false
+		}
+		var words []string
+		for key := range nested {
+			if key != "must" && key != "must_not" {
+				return "", nil, false
+			}
+		}
+		for _, key := range []string{"must", "must_not"} {
+			queries, err := clauseQueries(nested, key, "")
+			if err != nil {
+				return "", nil, false
+			}
+			for _, mq := range queries {
+				word, ok := phrase(mq)
+				if !ok {
+					return "", nil, false
+				}
+				if key == "must_not" {
+					word = "-" + word
+				}
+				words = append(words, word)
+			}
+		}
+		if len(words) == 0 {
+			return "", nil, false
+		}
+		if len(words) == 1 && !strings.HasPrefix(words[0], "-") {
+			return "", nil, false
+		}
+		values = append(values, strings.Join(words, "+"))
+	}
+	return field, values, true
+}
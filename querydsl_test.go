@@ -0,0 +1,514 @@
+package esutils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQueryDSL_roundTrip(t *testing.T) {
+	param1 := make(map[string]interface{})
+	param1["to"] = 0.4
+	param1["include_upper"] = false
+	param1["include_lower"] = true
+
+	param2 := make(map[string]interface{})
+	param2["from"] = 0.6
+	param2["include_upper"] = true
+	param2["include_lower"] = false
+
+	tests := []struct {
+		name       string
+		queryConds []QueryCond
+	}{
+		{
+			name: "1",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"text":    {"考生"},
+						"school":  {"西安理工+西安交大"},
+						"address": {"北京+-西安"},
+						"company": {"-unionj"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  MATCHPHRASE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"text": {"高考"},
+					},
+					QueryLogic: MUST,
+					QueryType:  MATCHPHRASE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"text": {"北京高考"},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  MATCHPHRASE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"content":      {"北京"},
+						"content_full": {"unionj"},
+					},
+					QueryLogic: MUST,
+					QueryType:  TERMS,
+				},
+			},
+		},
+		{
+			name: "2",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"type.keyword": {"education"},
+						"status":       {float64(200)},
+					},
+					QueryLogic: MUST,
+					QueryType:  TERMS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"dept.keyword": {"unionj*"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  WILDCARD,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"name.keyword": {"unionj"},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  PREFIX,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"senseResult": {param1},
+					},
+					QueryLogic: MUST,
+					QueryType:  RANGE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"visitSenseResult": {param2},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  RANGE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"delete_at": {},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  EXISTS,
+				},
+			},
+		},
+		{
+			name: "3",
+			queryConds: []QueryCond{
+				{
+					QueryLogic: MUSTNOT,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"type": {"网络调查"},
+							},
+							QueryLogic: MUST,
+							QueryType:  TERMS,
+						},
+						{
+							QueryLogic: SHOULD,
+							Children: []QueryCond{
+								{
+									Pair: map[string][]interface{}{
+										"price": {float64(0)},
+									},
+									QueryLogic: SHOULD,
+									QueryType:  TERMS,
+								},
+								{
+									Pair: map[string][]interface{}{
+										"free": {true},
+									},
+									QueryLogic: SHOULD,
+									QueryType:  TERMS,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "4",
+			queryConds: []QueryCond{
+				{
+					QueryLogic: SHOULD,
+					QueryType:  NESTED,
+					Path:       "line",
+					ScoreMode:  "sum",
+					InnerHits: &InnerHits{
+						Name: "lines",
+						Size: 5,
+					},
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"line.sku": {"A001"},
+							},
+							QueryLogic: MUST,
+							QueryType:  TERMS,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"line.name": {"apple"},
+							},
+							QueryLogic: MUSTNOT,
+							QueryType:  MATCHPHRASE,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "5",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"location": {map[string]interface{}{
+							"lat":           float64(40),
+							"lon":           float64(-70),
+							"distance":      "200km",
+							"distance_type": "plane",
+						}},
+					},
+					QueryLogic: MUST,
+					QueryType:  GEODISTANCE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"location": {map[string]interface{}{
+							"top_left":     map[string]interface{}{"lat": 40.73, "lon": -74.1},
+							"bottom_right": map[string]interface{}{"lat": 40.01, "lon": -71.12},
+						}},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  GEOBOUNDINGBOX,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"location": {
+							map[string]interface{}{"lat": float64(40), "lon": float64(-70)},
+							map[string]interface{}{"lat": float64(30), "lon": float64(-80)},
+							map[string]interface{}{"lat": float64(20), "lon": float64(-90)},
+						},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  GEOPOLYGON,
+				},
+			},
+		},
+		{
+			name: "6",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"status": {"Open"},
+					},
+					QueryLogic:      MUST,
+					QueryType:       TERM,
+					CaseInsensitive: true,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"product": {"iphnoe"},
+					},
+					QueryLogic:    SHOULD,
+					QueryType:     FUZZY,
+					Fuzziness:     "AUTO",
+					PrefixLength:  1,
+					MaxExpansions: 20,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"sku": {"A[0-9]+"},
+					},
+					QueryLogic: MUST,
+					QueryType:  REGEXP,
+					Flags:      "ALL",
+				},
+				{
+					Pair: map[string][]interface{}{
+						"_id": {"1", "2"},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  IDS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"name": {"App"},
+					},
+					QueryLogic:      SHOULD,
+					QueryType:       PREFIX,
+					CaseInsensitive: true,
+				},
+			},
+		},
+		{
+			name: "7",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"type": {"education", "sport"},
+					},
+					QueryLogic: FILTER,
+					QueryType:  TERMS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"text": {"考生"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  MATCHPHRASE,
+					Boost:      3,
+				},
+				{
+					QueryLogic:         MUST,
+					MinimumShouldMatch: "2",
+					Boost:              1.5,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"school": {"西安理工"},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  TERM,
+							Boost:      2,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"score": {map[string]interface{}{
+									"from":          float64(60),
+									"include_lower": true,
+								}},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  RANGE,
+							Boost:      0.5,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"_id": {"1"},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  IDS,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "8",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"title": {"quick br"},
+					},
+					QueryLogic: MUST,
+					QueryType:  BOOLPREFIX,
+					Boost:      2,
+				},
+			},
+		},
+		{
+			name: "9",
+			queryConds: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"title": {"quick br"},
+					},
+					QueryLogic:     MUST,
+					QueryType:      BOOLPREFIX,
+					MaxShingleSize: 4,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := query("", "", "", tt.queryConds, "", loc).Source()
+			if err != nil {
+				panic(err)
+			}
+			dsl, _ := json.Marshal(map[string]interface{}{"query": src})
+			got, err := ParseQueryDSL(dsl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTrip, err := query("", "", "", got, "", loc).Source()
+			if err != nil {
+				panic(err)
+			}
+			want := gabs.Wrap(src)
+			_src := gabs.Wrap(roundTrip)
+			for _, clause := range []string{"must", "filter", "should", "must_not"} {
+				assert.ElementsMatch(t, asSlice(want.Path("bool."+clause).Data()), asSlice(_src.Path("bool."+clause).Data()), clause)
+			}
+			assert.Equal(t, want.Path("bool.minimum_should_match").Data(), _src.Path("bool.minimum_should_match").Data())
+		})
+	}
+}
+
+func asSlice(data interface{}) []interface{} {
+	if data == nil {
+		return nil
+	}
+	if s, ok := data.([]interface{}); ok {
+		return s
+	}
+	return []interface{}{data}
+}
+
+func TestParseQueryDSL(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsl      string
+		want     []QueryCond
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name: "kibana",
+			dsl:  `{"query":{"bool":{"filter":[{"term":{"status":{"value":"open"}}},{"range":{"price":{"gte":10,"lt":100}}}],"must_not":{"exists":{"field":"deleted_at"}}}}}`,
+			want: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"status": {"open"},
+					},
+					QueryLogic: FILTER,
+					QueryType:  TERM,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"price": {map[string]interface{}{
+							"from":          float64(10),
+							"include_lower": true,
+							"to":            float64(100),
+							"include_upper": false,
+						}},
+					},
+					QueryLogic: FILTER,
+					QueryType:  RANGE,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"deleted_at": {},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  EXISTS,
+				},
+			},
+		},
+		{
+			name: "optional should on root",
+			dsl:  `{"bool":{"must":{"terms":{"type":["a","b"]}},"should":{"prefix":{"name":"uni"}}}}`,
+			want: []QueryCond{
+				{
+					QueryLogic: MUST,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"type": {"a", "b"},
+							},
+							QueryLogic: MUST,
+							QueryType:  TERMS,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"name": {"uni"},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  PREFIX,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "match_all",
+			dsl:  `{"query":{"match_all":{}}}`,
+			want: nil,
+		},
+		{
+			name:     "unsupported query type",
+			dsl:      `{"query":{"bool":{"must":[{"terms":{"type":["a"]}},{"more_like_this":{"fields":["title"],"like":"go"}}]}}}`,
+			wantPath: "query.bool.must[1].more_like_this",
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported range option",
+			dsl:      `{"range":{"createAt":{"gte":"now-7d/d","format":"yyyy-MM-dd"}}}`,
+			wantPath: "$.range.createAt.format",
+			wantErr:  true,
+		},
+		{
+			name: "minimum_should_match and boost",
+			dsl:  `{"bool":{"should":[{"terms":{"a":[1],"boost":2}},{"term":{"b":{"value":1,"boost":0.5}}}],"minimum_should_match":"2"}}`,
+			want: []QueryCond{
+				{
+					QueryLogic:         MUST,
+					MinimumShouldMatch: "2",
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"a": {float64(1)},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  TERMS,
+							Boost:      2,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"b": {float64(1)},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  TERM,
+							Boost:      0.5,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "unsupported bool option",
+			dsl:      `{"bool":{"should":[{"terms":{"a":[1]}},{"terms":{"b":[1]}}],"_name":"ab"}}`,
+			wantPath: "$.bool._name",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid json",
+			dsl:      `{"query":`,
+			wantPath: "$",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQueryDSL([]byte(tt.dsl))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseQueryDSL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equal(t, tt.wantPath, err.(*QueryDSLError).Path)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package esutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// QueryStringError reports why and where a search expression failed to parse
+type QueryStringError struct {
+	// Pos is the offset in runes from the beginning of the expression, starting from 0
+	Pos int    `json:"pos"`
+	Msg string `json:"msg"`
+}
+
+func (e *QueryStringError) Error() string {
+	return fmt.Sprintf("query string error at position %d: %s", e.Pos, e.Msg)
+}
+
+type qsTokenType int
+
+const (
+	qsEOF qsTokenType = iota
+	qsTerm
+	qsPhrase
+	qsColon
+	qsLParen
+	qsRParen
+	qsLBracket
+	qsRBracket
+	qsLBrace
+	qsRBrace
+	qsAnd
+	qsOr
+	qsNot
+	qsPlus
+)
+
+type qsToken struct {
+	typ qsTokenType
+	// text is the unescaped text of a term or phrase
+	text string
+	// raw is the text of a term as typed, escapes kept
+	raw string
+	// wild is true if the term contains unescaped * or ?
+	wild bool
+	pos  int
+}
+
+func (t qsToken) String() string {
+	switch t.typ {
+	case qsEOF:
+		return "end of input"
+	case qsPhrase:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.raw)
+	}
+}
+
+func qsSpecial(r rune) bool {
+	return strings.ContainsRune(`():"[]{}`, r)
+}
+
+func lexQueryString(input []rune) ([]qsToken, error) {
+	var tokens []qsToken
+	singles := map[rune]qsTokenType{
+		'(': qsLParen,
+		')': qsRParen,
+		':': qsColon,
+		'[': qsLBracket,
+		']': qsRBracket,
+		'{': qsLBrace,
+		'}': qsRBrace,
+	}
+	i := 0
+	for i < len(input) {
+		r := input[i]
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+		if typ, ok := singles[r]; ok {
+			tokens = append(tokens, qsToken{typ: typ, raw: string(r), pos: i})
+			i++
+			continue
+		}
+		if r == '"' {
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(input) && input[i] != '"' {
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				}
+				sb.WriteRune(input[i])
+				i++
+			}
+			if i >= len(input) {
+				return nil, &QueryStringError{Pos: start, Msg: "unterminated quoted phrase"}
+			}
+			i++
+			tokens = append(tokens, qsToken{typ: qsPhrase, text: sb.String(), raw: string(input[start:i]), pos: start})
+			continue
+		}
+		if (r == '&' || r == '|') && i+1 < len(input) && input[i+1] == r {
+			typ := qsAnd
+			if r == '|' {
+				typ = qsOr
+			}
+			tokens = append(tokens, qsToken{typ: typ, raw: string(input[i : i+2]), pos: i})
+			i += 2
+			continue
+		}
+		// - and + are operators only at the beginning of a clause, e.g. -tag:spam, but not in 2020-01-01 or [-10 TO 10]
+		if (r == '-' || r == '+') && (i == 0 || unicode.IsSpace(input[i-1]) || input[i-1] == '(') &&
+			i+1 < len(input) && !unicode.IsSpace(input[i+1]) {
+			typ := qsNot
+			if r == '+' {
+				typ = qsPlus
+			}
+			tokens = append(tokens, qsToken{typ: typ, raw: string(r), pos: i})
+			i++
+			continue
+		}
+		start := i
+		var (
+			sb   strings.Builder
+			wild bool
+		)
+		for i < len(input) && !unicode.IsSpace(input[i]) && !qsSpecial(input[i]) {
+			if input[i] == '\\' {
+				if i+1 >= len(input) {
+					return nil, &QueryStringError{Pos: i, Msg: "escape character at end of input"}
+				}
+				i++
+			} else if input[i] == '*' || input[i] == '?' {
+				wild = true
+			}
+			sb.WriteRune(input[i])
+			i++
+		}
+		raw := string(input[start:i])
+		typ := qsTerm
+		switch raw {
+		case "AND":
+			typ = qsAnd
+		case "OR":
+			typ = qsOr
+		case "NOT":
+			typ = qsNot
+		}
+		tokens = append(tokens, qsToken{typ: typ, text: sb.String(), raw: raw, wild: wild, pos: start})
+	}
+	tokens = append(tokens, qsToken{typ: qsEOF, pos: len(input)})
+	return tokens, nil
+}
+
+type qsOp int
+
+const (
+	qsLeaf qsOp = iota
+	qsAndOp
+	qsOrOp
+	qsNotOp
+)
+
+type qsNode struct {
+	op       qsOp
+	children []*qsNode
+	cond     QueryCond
+}
+
+type qsParser struct {
+	tokens       []qsToken
+	cur          int
+	defaultField string
+}
+
+func (p *qsParser) peek() qsToken {
+	return p.tokens[p.cur]
+}
+
+func (p *qsParser) next() qsToken {
+	t := p.tokens[p.cur]
+	if t.typ != qsEOF {
+		p.cur++
+	}
+	return t
+}
+
+func (p *qsParser) parseOr() (*qsNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*qsNode{left}
+	for p.peek().typ == qsOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return &qsNode{op: qsOrOp, children: nodes}, nil
+}
+
+func (p *qsParser) parseAnd() (*qsNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*qsNode{left}
+	for {
+		switch p.peek().typ {
+		case qsAnd:
+			p.next()
+		case qsTerm, qsPhrase, qsLParen, qsNot, qsPlus:
+			// clauses next to each other are joined by AND
+		default:
+			if len(nodes) == 1 {
+				return left, nil
+			}
+			return &qsNode{op: qsAndOp, children: nodes}, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+}
+
+func (p *qsParser) parseUnary() (*qsNode, error) {
+	switch p.peek().typ {
+	case qsNot:
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &qsNode{op: qsNotOp, children: []*qsNode{x}}, nil
+	case qsPlus:
+		p.next()
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *qsParser) parsePrimary() (*qsNode, error) {
+	t := p.peek()
+	switch t.typ {
+	case qsLParen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().typ != qsRParen {
+			return nil, &QueryStringError{Pos: p.peek().pos, Msg: fmt.Sprintf("expected \")\" to close \"(\" at position %d, got %s", t.pos, p.peek())}
+		}
+		p.next()
+		return x, nil
+	case qsTerm:
+		p.next()
+		if p.peek().typ == qsColon {
+			p.next()
+			if stringutils.IsEmpty(t.text) || t.wild {
+				return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("invalid field name %s", t)}
+			}
+			return p.parseValue(t.text)
+		}
+		if stringutils.IsEmpty(p.defaultField) {
+			return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("expected field:value, got %s", t)}
+		}
+		return p.leaf(p.defaultField, t)
+	case qsPhrase:
+		if stringutils.IsEmpty(p.defaultField) {
+			return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("expected field:value, got %s", t)}
+		}
+		p.next()
+		return p.leaf(p.defaultField, t)
+	}
+	return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+}
+
+func (p *qsParser) parseValue(field string) (*qsNode, error) {
+	t := p.peek()
+	switch t.typ {
+	case qsTerm, qsPhrase:
+		p.next()
+		return p.leaf(field, t)
+	case qsLBracket, qsLBrace:
+		return p.parseRange(field)
+	}
+	return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("expected value for field %q, got %s", field, t)}
+}
+
+func (p *qsParser) leaf(field string, t qsToken) (*qsNode, error) {
+	qc := QueryCond{
+		Pair: make(map[string][]interface{}),
+	}
+	switch {
+	case t.typ == qsPhrase:
+		// match_phrase values have their own + and - syntax, reject them rather than change the meaning silently
+		if strings.Contains(t.text, "+") || strings.HasPrefix(strings.TrimSpace(t.text), "-") {
+			return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("phrase %s must not contain \"+\" or start with \"-\"", t)}
+		}
+		if stringutils.IsEmpty(strings.TrimSpace(t.text)) {
+			return nil, &QueryStringError{Pos: t.pos, Msg: "empty phrase"}
+		}
+		qc.QueryType = MATCHPHRASE
+		qc.Pair[field] = []interface{}{t.text}
+	case t.raw == "*":
+		qc.QueryType = EXISTS
+		qc.Pair[field] = []interface{}{}
+	case t.wild:
+		if strings.HasSuffix(t.raw, "*") && !strings.ContainsAny(t.raw[:len(t.raw)-1], `*?\`) {
+			qc.QueryType = PREFIX
+			qc.Pair[field] = []interface{}{strings.TrimSuffix(t.raw, "*")}
+		} else {
+			qc.QueryType = WILDCARD
+			qc.Pair[field] = []interface{}{t.raw}
+		}
+	default:
+		qc.QueryType = TERMS
+		qc.Pair[field] = []interface{}{t.text}
+	}
+	return &qsNode{op: qsLeaf, cond: qc}, nil
+}
+
+func (p *qsParser) parseRange(field string) (*qsNode, error) {
+	open := p.next()
+	from, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	if to := p.peek(); to.typ != qsTerm || to.raw != "TO" {
+		return nil, &QueryStringError{Pos: to.pos, Msg: fmt.Sprintf("expected \"TO\" in range, got %s", to)}
+	}
+	p.next()
+	to, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	end := p.peek()
+	if end.typ != qsRBracket && end.typ != qsRBrace {
+		return nil, &QueryStringError{Pos: end.pos, Msg: fmt.Sprintf("expected \"]\" or \"}\" to close range at position %d, got %s", open.pos, end)}
+	}
+	p.next()
+	if from == nil && to == nil {
+		return nil, &QueryStringError{Pos: open.pos, Msg: "range must have at least one bound"}
+	}
+	params := map[string]interface{}{
+		"from":          from,
+		"to":            to,
+		"include_lower": open.typ == qsLBracket,
+		"include_upper": end.typ == qsRBracket,
+	}
+	return &qsNode{op: qsLeaf, cond: QueryCond{
+		Pair: map[string][]interface{}{
+			field: {params},
+		},
+		QueryType: RANGE,
+	}}, nil
+}
+
+// parseBound returns nil for *, float64 for numbers and string for others such as dates
+func (p *qsParser) parseBound() (interface{}, error) {
+	t := p.next()
+	switch t.typ {
+	case qsPhrase:
+		return t.text, nil
+	case qsTerm:
+		if t.raw == "*" {
+			return nil, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+		return t.text, nil
+	}
+	return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("expected range bound, got %s", t)}
+}
+
+// toQueryCond converts the node to a QueryCond joined to its parent by logic
+func (n *qsNode) toQueryCond(logic queryLogic) QueryCond {
+	switch n.op {
+	case qsNotOp:
+		switch logic {
+		case MUST:
+			return n.children[0].toQueryCond(MUSTNOT)
+		case MUSTNOT:
+			return n.children[0].toQueryCond(MUST)
+		default:
+			return QueryCond{
+				QueryLogic: logic,
+				Children:   []QueryCond{n.children[0].toQueryCond(MUSTNOT)},
+			}
+		}
+	case qsAndOp, qsOrOp:
+		childLogic := MUST
+		if n.op == qsOrOp {
+			childLogic = SHOULD
+		}
+		qc := QueryCond{
+			QueryLogic: logic,
+		}
+		for _, child := range n.children {
+			qc.Children = append(qc.Children, child.toQueryCond(childLogic))
+		}
+		return qc
+	}
+	qc := n.cond
+	qc.QueryLogic = logic
+	return qc
+}
+
+// ParseQueryString parses a human search expression into QueryConds for Paging.QueryConds, e.g.
+//
+//	title:"foo bar" AND (status:open OR status:pending) -tag:spam price:[10 TO 100]
+//
+// Supported syntax:
+//
+//	field:value       TERMS query, field:* is EXISTS query, field:value* is PREFIX query, other * and ? make WILDCARD query
+//	field:"a phrase"  MATCHPHRASE query
+//	field:[1 TO 10]   RANGE query, [ ] are inclusive, { } are exclusive, * means unbounded
+//	AND, &&, OR, ||, NOT, -, +, ( )
+//
+// Clauses without operator between them are joined by AND, and AND binds tighter than OR.
+// Terms without field are matched against defaultField, or rejected if defaultField is empty.
+// The returned error is a *QueryStringError carrying the position of the problem.
+func ParseQueryString(input string, defaultField string) ([]QueryCond, error) {
+	tokens, err := lexQueryString([]rune(input))
+	if err != nil {
+		return nil, err
+	}
+	p := &qsParser{
+		tokens:       tokens,
+		defaultField: defaultField,
+	}
+	if p.peek().typ == qsEOF {
+		return nil, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != qsEOF {
+		return nil, &QueryStringError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	var conds []QueryCond
+	switch root.op {
+	case qsAndOp:
+		for _, child := range root.children {
+			conds = append(conds, child.toQueryCond(MUST))
+		}
+	case qsOrOp:
+		for _, child := range root.children {
+			conds = append(conds, child.toQueryCond(SHOULD))
+		}
+	default:
+		conds = append(conds, root.toQueryCond(MUST))
+	}
+	return conds, nil
+}
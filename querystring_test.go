@@ -0,0 +1,195 @@
+package esutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQueryString(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		defaultField string
+		want         []QueryCond
+		wantPos      int
+		wantErr      bool
+	}{
+		{
+			name:  "1",
+			input: `title:"foo bar" AND (status:open OR status:pending) -tag:spam price:[10 TO 100]`,
+			want: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"title": {"foo bar"},
+					},
+					QueryLogic: MUST,
+					QueryType:  MATCHPHRASE,
+				},
+				{
+					QueryLogic: MUST,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"status": {"open"},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  TERMS,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"status": {"pending"},
+							},
+							QueryLogic: SHOULD,
+							QueryType:  TERMS,
+						},
+					},
+				},
+				{
+					Pair: map[string][]interface{}{
+						"tag": {"spam"},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  TERMS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"price": {map[string]interface{}{
+							"from":          float64(10),
+							"to":            float64(100),
+							"include_lower": true,
+							"include_upper": true,
+						}},
+					},
+					QueryLogic: MUST,
+					QueryType:  RANGE,
+				},
+			},
+		},
+		{
+			name:  "2",
+			input: `dept:unionj* || NOT city:四川?? || createAt:{2020-01-01 TO *] flag:*`,
+			want: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"dept": {"unionj"},
+					},
+					QueryLogic: SHOULD,
+					QueryType:  PREFIX,
+				},
+				{
+					QueryLogic: SHOULD,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"city": {"四川??"},
+							},
+							QueryLogic: MUSTNOT,
+							QueryType:  WILDCARD,
+						},
+					},
+				},
+				{
+					QueryLogic: SHOULD,
+					Children: []QueryCond{
+						{
+							Pair: map[string][]interface{}{
+								"createAt": {map[string]interface{}{
+									"from":          "2020-01-01",
+									"to":            nil,
+									"include_lower": false,
+									"include_upper": true,
+								}},
+							},
+							QueryLogic: MUST,
+							QueryType:  RANGE,
+						},
+						{
+							Pair: map[string][]interface{}{
+								"flag": {},
+							},
+							QueryLogic: MUST,
+							QueryType:  EXISTS,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:         "3",
+			input:        `考生 -"北京高考"`,
+			defaultField: "text",
+			want: []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"text": {"考生"},
+					},
+					QueryLogic: MUST,
+					QueryType:  TERMS,
+				},
+				{
+					Pair: map[string][]interface{}{
+						"text": {"北京高考"},
+					},
+					QueryLogic: MUSTNOT,
+					QueryType:  MATCHPHRASE,
+				},
+			},
+		},
+		{
+			name:  "4",
+			input: "",
+			want:  nil,
+		},
+		{
+			name:    "5",
+			input:   `title:"foo`,
+			wantPos: 6,
+			wantErr: true,
+		},
+		{
+			name:    "6",
+			input:   `(status:open OR status:pending`,
+			wantPos: 30,
+			wantErr: true,
+		},
+		{
+			name:    "7",
+			input:   `price:[10 100]`,
+			wantPos: 10,
+			wantErr: true,
+		},
+		{
+			name:    "8",
+			input:   `status:open AND`,
+			wantPos: 15,
+			wantErr: true,
+		},
+		{
+			name:    "9",
+			input:   `status:open foo`,
+			wantPos: 12,
+			wantErr: true,
+		},
+		{
+			name:    "10",
+			input:   `text:"北京+-西安"`,
+			wantPos: 5,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQueryString(tt.input, tt.defaultField)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseQueryString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equal(t, tt.wantPos, err.(*QueryStringError).Pos)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
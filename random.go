@@ -2,20 +2,40 @@ package esutils
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/olivere/elastic/v7"
 	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/copier"
 	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
 	"time"
 )
 
-// Random if paging is nil, randomly return 10 pcs of documents as default
-func (es *Es) Random(ctx context.Context, paging *Paging) ([]map[string]interface{}, error) {
+// randomQuery scores docs matching boolQuery by random_score only, docs are shuffled in the same order
+// for the same paging.RandomSeed
+func randomQuery(boolQuery *elastic.BoolQuery, paging *Paging) *elastic.FunctionScoreQuery {
+	fn := elastic.NewRandomFunction()
+	if stringutils.IsNotEmpty(paging.RandomSeed) {
+		field := paging.RandomField
+		if stringutils.IsEmpty(field) {
+			field = "_seq_no"
+		}
+		fn.Seed(paging.RandomSeed).Field(field)
+	}
+	return elastic.NewFunctionScoreQuery().Query(boolQuery).AddScoreFunc(fn).BoostMode("replace")
+}
+
+// Random if paging is nil, randomly return 10 pcs of documents as default.
+// Set paging.RandomSeed to get the same docs for the same paging, Skip pages through the shuffled docs stably.
+// Docs have _id, Includes and Excludes are applied, and Sortby breaks ties of random score.
+func (es *Es) Random(ctx context.Context, paging *Paging) (rets []map[string]interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "Random", &Call{Paging: paging})
+	defer func() { err = op.end(rets, err) }()
+	if err != nil {
+		return nil, err
+	}
+	paging = op.call.Paging
 	var (
-		err       error
 		boolQuery *elastic.BoolQuery
 		sr        *elastic.SearchResult
-		rets      []map[string]interface{}
 	)
 	if paging == nil {
 		paging = &Paging{
@@ -29,15 +49,96 @@ func (es *Es) Random(ctx context.Context, paging *Paging) ([]map[string]interfac
 			return nil, errors.Wrap(err, "call LoadLocation() error")
 		}
 	}
-	boolQuery = query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, zone)
-	fsq := elastic.NewFunctionScoreQuery().Query(boolQuery).AddScoreFunc(elastic.NewScriptFunction(elastic.NewScriptInline("Math.random()")))
-	if sr, err = es.client.Search().Index(es.esIndex).Type(es.esType).Query(fsq).From(paging.Skip).Size(paging.Limit).Do(ctx); err != nil {
+	if boolQuery, err = pagingQuery(paging, zone); err != nil {
+		return nil, errors.Wrap(err, "call pagingQuery() error")
+	}
+	fsc := elastic.NewFetchSourceContext(true)
+	if len(paging.Includes) > 0 {
+		fsc = fsc.Include(paging.Includes...)
+	}
+	if len(paging.Excludes) > 0 {
+		fsc = fsc.Exclude(paging.Excludes...)
+	}
+	p := *paging
+	if len(p.Sortby) > 0 {
+		p.Sortby = append([]Sort{{Field: "_score"}}, p.Sortby...)
+	}
+	ss := es.client.Search().Index(es.esIndex).Type(es.esType).Query(randomQuery(boolQuery, &p)).FetchSourceContext(fsc)
+	for _, v := range p.Sortby {
+		ss = ss.SortBy(v.sorter())
+	}
+	if sr, err = ss.From(p.Skip).Size(p.Limit).Do(ctx); err != nil {
 		return nil, errors.Wrap(err, "call Search() error")
 	}
+	recordSearch(ctx, sr)
 	for _, hit := range sr.Hits.Hits {
-		var ret map[string]interface{}
-		json.Unmarshal(hit.Source, &ret)
-		rets = append(rets, ret)
+		ret, _ := hitToDoc(hit, &p, nil)
+		rets = append(rets, ret.(map[string]interface{}))
 	}
 	return rets, nil
 }
+
+// Sample runs aggr on a random sample of docs matching paging, at most shardSize docs are sampled from each shard.
+// It is useful for statistically representative results of expensive aggregations on large indices.
+// Set paging.RandomSeed for a reproducible sample. aggr only accept map[string]interface{} or elastic.Aggregation like Stat,
+// the returned map holds doc_count of the sample and results of aggr
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-aggregations-bucket-sampler-aggregation.html
+func (es *Es) Sample(ctx context.Context, paging *Paging, shardSize int, aggr interface{}) (result map[string]interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "Sample", &Call{Paging: paging, Body: aggr})
+	defer func() { err = op.end(result, err) }()
+	if err != nil {
+		return nil, err
+	}
+	paging = op.call.Paging
+	var (
+		boolQuery *elastic.BoolQuery
+		sr        *elastic.SearchResult
+	)
+	if paging == nil {
+		paging = &Paging{}
+	}
+	var zone *time.Location
+	if stringutils.IsNotEmpty(paging.Zone) {
+		zone, err = time.LoadLocation(paging.Zone)
+		if err != nil {
+			return nil, errors.Wrap(err, "call LoadLocation() error")
+		}
+	}
+	if boolQuery, err = pagingQuery(paging, zone); err != nil {
+		return nil, errors.Wrap(err, "call pagingQuery() error")
+	}
+	searchService := es.client.Search().Index(es.esIndex).Type(es.esType)
+	switch raw := aggr.(type) {
+	case map[string]interface{}:
+		sampler := map[string]interface{}{}
+		if shardSize > 0 {
+			sampler["shard_size"] = shardSize
+		}
+		src, _ := randomQuery(boolQuery, paging).Source()
+		if sr, err = searchService.Source(map[string]interface{}{
+			"query": src,
+			"size":  0,
+			"aggs": map[string]interface{}{
+				"sample": map[string]interface{}{
+					"sampler": sampler,
+					"aggs":    raw,
+				},
+			},
+		}).Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "call Search() error")
+		}
+	case elastic.Aggregation:
+		sampler := elastic.NewSamplerAggregation().SubAggregation("volume", raw)
+		if shardSize > 0 {
+			sampler.ShardSize(shardSize)
+		}
+		if sr, err = searchService.Query(randomQuery(boolQuery, paging)).Size(0).Aggregation("sample", sampler).Do(ctx); err != nil {
+			return nil, errors.Wrap(err, "call Search() error")
+		}
+	default:
+		return nil, errors.New("aggr only accept map[string]interface{} or elastic.Aggregation")
+	}
+	recordSearch(ctx, sr)
+	copier.DeepCopy(sr.Aggregations["sample"], &result)
+	return result, nil
+}
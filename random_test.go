@@ -2,11 +2,12 @@ package esutils
 
 import (
 	"context"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestRandom(t *testing.T) {
-	es := setupSubTest("test_random")
+	es := setupSubTest(t)
 	type args struct {
 		paging *Paging
 	}
@@ -90,3 +91,47 @@ func TestRandom(t *testing.T) {
 		})
 	}
 }
+
+func TestRandom_seed(t *testing.T) {
+	es := setupSubTest(t)
+	paging := &Paging{
+		Limit:      3,
+		RandomSeed: "10",
+		Includes:   []string{"type"},
+	}
+	first, err := es.Random(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, first, 3)
+	for _, doc := range first {
+		assert.NotEmpty(t, doc["_id"])
+		assert.NotNil(t, doc["type"])
+		assert.Nil(t, doc["text"])
+	}
+	for i := 0; i < 5; i++ {
+		got, err := es.Random(context.Background(), paging)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, first, got)
+	}
+}
+
+func TestEs_Sample(t *testing.T) {
+	es := setupSubTest(t)
+	got, err := es.Sample(context.Background(), &Paging{
+		RandomSeed: "10",
+	}, 100, map[string]interface{}{
+		"types": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "type.keyword",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(3), got["doc_count"])
+	assert.Len(t, got["types"].(map[string]interface{})["buckets"], 3)
+}
@@ -0,0 +1,150 @@
+package esutils
+
+import (
+	"github.com/olivere/elastic/v7"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// Relevance tunes score of docs matching QueryConds by function_score query
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/query-dsl-function-score-query.html
+type Relevance struct {
+	Functions []ScoreFunction `json:"functions"`
+	// ScoreMode combines scores of Functions, one of multiply, sum, avg, first, max and min, default is multiply
+	ScoreMode string `json:"scoreMode"`
+	// BoostMode combines query score and function score, one of multiply, replace, sum, avg, max and min, default is multiply
+	BoostMode string `json:"boostMode"`
+	// MaxBoost caps the function score if greater than 0
+	MaxBoost float64 `json:"maxBoost"`
+	// MinScore excludes docs whose score is lower than it if greater than 0
+	MinScore float64 `json:"minScore"`
+}
+
+// ScoreFunction is one of function_score functions, at most one of FieldValueFactor, Decay and Script should be set.
+// If none of them is set, Weight is used as score of docs matching Filter.
+type ScoreFunction struct {
+	// Filter restricts the function to docs matching the conditions
+	Filter []QueryCond `json:"filter"`
+	// Weight multiplies the score of the function if greater than 0
+	Weight           float64           `json:"weight"`
+	FieldValueFactor *FieldValueFactor `json:"fieldValueFactor"`
+	Decay            *Decay            `json:"decay"`
+	Script           *ScoreScript      `json:"script"`
+}
+
+// FieldValueFactor scores docs by a numeric field, e.g. popularity
+type FieldValueFactor struct {
+	Field  string  `json:"field"`
+	Factor float64 `json:"factor"`
+	// Modifier is one of none, log, log1p, log2p, ln, ln1p, ln2p, square, sqrt and reciprocal
+	Modifier string `json:"modifier"`
+	// Missing is used for docs without the field
+	Missing *float64 `json:"missing"`
+}
+
+// Decay scores docs by distance of a date, numeric or geo_point field from Origin, e.g. recency
+type Decay struct {
+	// Type is one of gauss, exp and linear, default is gauss
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	// Origin is a date or date math like now for date fields, a number for numeric fields and a GeoPoint for geo_point fields
+	Origin interface{} `json:"origin"`
+	// Scale is the distance from Origin where score is Decay, e.g. 10d or 2km
+	Scale  interface{} `json:"scale"`
+	Offset interface{} `json:"offset"`
+	// Decay is the score at Scale, default is 0.5
+	Decay float64 `json:"decay"`
+	// MultiValueMode is one of min, max, avg and sum
+	MultiValueMode string `json:"multiValueMode"`
+}
+
+// ScoreScript scores docs by a script like "_score * doc['likes'].value / params.base"
+type ScoreScript struct {
+	Source string                 `json:"source"`
+	Params map[string]interface{} `json:"params"`
+	Lang   string                 `json:"lang"`
+}
+
+func (f ScoreFunction) scoreFunc() elastic.ScoreFunction {
+	switch {
+	case f.FieldValueFactor != nil:
+		fvf := elastic.NewFieldValueFactorFunction().Field(f.FieldValueFactor.Field)
+		if f.FieldValueFactor.Factor > 0 {
+			fvf.Factor(f.FieldValueFactor.Factor)
+		}
+		if stringutils.IsNotEmpty(f.FieldValueFactor.Modifier) {
+			fvf.Modifier(f.FieldValueFactor.Modifier)
+		}
+		if f.FieldValueFactor.Missing != nil {
+			fvf.Missing(*f.FieldValueFactor.Missing)
+		}
+		if f.Weight > 0 {
+			fvf.Weight(f.Weight)
+		}
+		return fvf
+	case f.Decay != nil:
+		return f.Decay.scoreFunc(f.Weight)
+	case f.Script != nil:
+		script := elastic.NewScript(f.Script.Source).Params(f.Script.Params)
+		if stringutils.IsNotEmpty(f.Script.Lang) {
+			script.Lang(f.Script.Lang)
+		}
+		sf := elastic.NewScriptFunction(script)
+		if f.Weight > 0 {
+			sf.Weight(f.Weight)
+		}
+		return sf
+	}
+	return elastic.NewWeightFactorFunction(f.Weight)
+}
+
+func (d Decay) scoreFunc(weight float64) elastic.ScoreFunction {
+	fn := elastic.NewGaussDecayFunction().FieldName(d.Field).Origin(d.Origin).Scale(d.Scale)
+	if d.Offset != nil {
+		fn.Offset(d.Offset)
+	}
+	if d.Decay > 0 {
+		fn.Decay(d.Decay)
+	}
+	if stringutils.IsNotEmpty(d.MultiValueMode) {
+		fn.MultiValueMode(d.MultiValueMode)
+	}
+	if weight > 0 {
+		fn.Weight(weight)
+	}
+	// decay functions of elastic have the same fields, the gauss one converts to the others
+	switch d.Type {
+	case "exp":
+		return (*elastic.ExponentialDecayFunction)(fn)
+	case "linear":
+		return (*elastic.LinearDecayFunction)(fn)
+	}
+	return fn
+}
+
+// scoreQuery wraps boolQuery in function_score query if relevance is given
+func scoreQuery(boolQuery *elastic.BoolQuery, relevance *Relevance) elastic.Query {
+	if relevance == nil {
+		return boolQuery
+	}
+	fsq := elastic.NewFunctionScoreQuery().Query(boolQuery)
+	for _, f := range relevance.Functions {
+		if len(f.Filter) > 0 {
+			fsq.Add(query("", "", "", f.Filter, "", nil), f.scoreFunc())
+		} else {
+			fsq.AddScoreFunc(f.scoreFunc())
+		}
+	}
+	if stringutils.IsNotEmpty(relevance.ScoreMode) {
+		fsq.ScoreMode(relevance.ScoreMode)
+	}
+	if stringutils.IsNotEmpty(relevance.BoostMode) {
+		fsq.BoostMode(relevance.BoostMode)
+	}
+	if relevance.MaxBoost > 0 {
+		fsq.MaxBoost(relevance.MaxBoost)
+	}
+	if relevance.MinScore > 0 {
+		fsq.MinScore(relevance.MinScore)
+	}
+	return fsq
+}
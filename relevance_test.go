@@ -0,0 +1,103 @@
+package esutils
+
+import (
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scoreQuery(t *testing.T) {
+	missing := float64(1)
+	relevance := &Relevance{
+		Functions: []ScoreFunction{
+			{
+				FieldValueFactor: &FieldValueFactor{
+					Field:    "likes",
+					Factor:   1.2,
+					Modifier: "log1p",
+					Missing:  &missing,
+				},
+			},
+			{
+				Decay: &Decay{
+					Type:   "exp",
+					Field:  "createAt",
+					Origin: "now",
+					Scale:  "10d",
+					Offset: "1d",
+					Decay:  0.5,
+				},
+				Weight: 2,
+			},
+			{
+				Decay: &Decay{
+					Field:  "location",
+					Origin: GeoPoint{Lat: 40, Lon: -70},
+					Scale:  "2km",
+				},
+			},
+			{
+				Script: &ScoreScript{
+					Source: "_score * doc['likes'].value / params.base",
+					Params: map[string]interface{}{
+						"base": 10,
+					},
+				},
+			},
+			{
+				Filter: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"type": {"education"},
+						},
+						QueryLogic: MUST,
+						QueryType:  TERMS,
+					},
+				},
+				Weight: 3,
+			},
+		},
+		ScoreMode: "sum",
+		BoostMode: "replace",
+		MaxBoost:  10,
+		MinScore:  0.5,
+	}
+	want := `{"function_score":{"boost_mode":"replace","functions":[{"field_value_factor":{"factor":1.2,"field":"likes","missing":1,"modifier":"log1p"}},{"exp":{"createAt":{"decay":0.5,"offset":"1d","origin":"now","scale":"10d"}},"weight":2},{"gauss":{"location":{"origin":{"lat":40,"lon":-70},"scale":"2km"}}},{"script_score":{"script":{"params":{"base":10},"source":"_score * doc['likes'].value / params.base"}}},{"filter":{"bool":{"must":{"terms":{"type":["education"]}}}},"weight":3}],"max_boost":10,"min_score":0.5,"query":{"bool":{"must":{"terms":{"type":["sport"]}}}},"score_mode":"sum"}}`
+	boolQuery := query("", "", "", []QueryCond{
+		{
+			Pair: map[string][]interface{}{
+				"type": {"sport"},
+			},
+			QueryLogic: MUST,
+			QueryType:  TERMS,
+		},
+	}, "", loc)
+	src, err := scoreQuery(boolQuery, relevance).Source()
+	if err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, want, gabs.Wrap(src).String())
+
+	src, err = scoreQuery(boolQuery, nil).Source()
+	if err != nil {
+		panic(err)
+	}
+	assert.JSONEq(t, `{"bool":{"must":{"terms":{"type":["sport"]}}}}`, gabs.Wrap(src).String())
+}
+
+func TestDecay_scoreFunc(t *testing.T) {
+	for _, typ := range []string{"gauss", "exp", "linear"} {
+		t.Run(typ, func(t *testing.T) {
+			decay := Decay{Type: typ, Field: "createAt", Origin: "now", Scale: "10d", Offset: "1d", Decay: 0.5, MultiValueMode: "avg"}
+			fn := decay.scoreFunc(2)
+			assert.Equal(t, typ, fn.Name())
+			assert.Equal(t, 2.0, *fn.GetWeight())
+			src, err := fn.Source()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.JSONEq(t, `{"createAt":{"decay":0.5,"offset":"1d","origin":"now","scale":"10d"},"multi_value_mode":"avg"}`, gabs.Wrap(src).String())
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package esutils_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+// newCluster returns a stand-in node answering sniffing and count requests
+func newCluster() *esutilstest.Server {
+	server := esutilstest.NewServer()
+	server.HandleFunc(http.MethodGet, "/_nodes/http", func(r esutilstest.Request) esutilstest.Response {
+		return esutilstest.Response{
+			Body: fmt.Sprintf(`{"nodes":{"node1":{"name":"node1","http":{"publish_address":"%s"}}}}`, strings.TrimPrefix(server.URL, "http://")),
+		}
+	})
+	server.Handle("", "/*/_doc/_count", esutilstest.Response{Body: `{"count":3}`})
+	return server
+}
+
+// countPaths returns number of requests of server with method and path
+func countPaths(server *esutilstest.Server, method, path string) int {
+	var n int
+	for _, p := range server.Paths() {
+		if p == method+" "+path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEs_retry(t *testing.T) {
+	policy := esutils.RetryPolicy{MaxRetries: 2, InitialInterval: time.Millisecond}
+	tests := []struct {
+		name       string
+		failures   int
+		status     int
+		opts       []esutils.EsOption
+		wantErr    bool
+		wantCounts int
+	}{
+		{
+			name:       "no retry by default",
+			failures:   1,
+			status:     http.StatusServiceUnavailable,
+			wantErr:    true,
+			wantCounts: 1,
+		},
+		{
+			name:       "retry 503",
+			failures:   2,
+			status:     http.StatusServiceUnavailable,
+			opts:       []esutils.EsOption{esutils.WithRetry(policy)},
+			wantCounts: 3,
+		},
+		{
+			name:       "retry 429",
+			failures:   1,
+			status:     http.StatusTooManyRequests,
+			opts:       []esutils.EsOption{esutils.WithRetry(policy)},
+			wantCounts: 2,
+		},
+		{
+			name:       "max retries exceeded",
+			failures:   3,
+			status:     http.StatusGatewayTimeout,
+			opts:       []esutils.EsOption{esutils.WithRetry(policy)},
+			wantErr:    true,
+			wantCounts: 3,
+		},
+		{
+			name:       "400 is not retried",
+			failures:   1,
+			status:     http.StatusBadRequest,
+			opts:       []esutils.EsOption{esutils.WithRetry(policy)},
+			wantErr:    true,
+			wantCounts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCluster()
+			defer server.Close()
+			var responses []esutilstest.Response
+			for i := 0; i < tt.failures; i++ {
+				responses = append(responses, esutilstest.ErrorResponse(tt.status, "unavailable", "unavailable"))
+			}
+			server.Handle("", "/*/_doc/_count", append(responses, esutilstest.Response{Body: `{"count":3}`})...)
+			es := esutils.NewEs("test_retry", append([]esutils.EsOption{esutils.WithUrls([]string{server.URL})}, tt.opts...)...)
+			got, err := es.Count(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Count() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, int64(3), got)
+			}
+			assert.Equal(t, tt.wantCounts, countPaths(server, http.MethodPost, "/test_retry/_doc/_count"))
+		})
+	}
+}
+
+func TestEs_retryNextNode(t *testing.T) {
+	down := newCluster()
+	down.Close()
+	up := newCluster()
+	defer up.Close()
+	es := esutils.NewEs("test_retry", esutils.WithUrls([]string{down.URL, up.URL}), esutils.WithRetry(esutils.RetryPolicy{MaxRetries: 2, InitialInterval: time.Millisecond}))
+	for i := 0; i < 4; i++ {
+		got, err := es.Count(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(3), got)
+	}
+}
+
+func TestEs_sniffAndHealthcheck(t *testing.T) {
+	server := newCluster()
+	defer server.Close()
+	es := esutils.NewEs("test_sniff", esutils.WithUrls([]string{server.URL}), esutils.WithSniff(true, time.Minute), esutils.WithHealthcheck(true, time.Minute))
+	got, err := es.Count(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), got)
+	assert.Positive(t, countPaths(server, http.MethodGet, "/_nodes/http"))
+	assert.Positive(t, countPaths(server, http.MethodHead, "/"))
+}
+
+func TestEs_requestTimeout(t *testing.T) {
+	server := newCluster()
+	defer server.Close()
+	server.Handle("", "/*/_doc/_count", esutilstest.Response{Body: `{"count":3}`, Delay: 200 * time.Millisecond})
+	es := esutils.NewEs("test_timeout", esutils.WithUrls([]string{server.URL}), esutils.WithRequestTimeout(50*time.Millisecond))
+	_, err := es.Count(context.Background(), nil)
+	assert.Error(t, err)
+}
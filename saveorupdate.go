@@ -8,15 +8,23 @@ import (
 )
 
 // SaveOrUpdate saves or updates doc
-func (es *Es) SaveOrUpdate(ctx context.Context, doc interface{}) (string, error) {
+func (es *Es) SaveOrUpdate(ctx context.Context, doc interface{}) (id string, err error) {
+	ctx, op, err := es.begin(ctx, "SaveOrUpdate", &Call{Write: true, Docs: []interface{}{doc}})
+	defer func() { err = op.end(id, err) }()
+	if err != nil {
+		return "", err
+	}
+	if len(op.call.Docs) != 1 {
+		return "", errors.Errorf("SaveOrUpdate must have exactly one doc, got %d", len(op.call.Docs))
+	}
+	doc = op.call.Docs[0]
 	var (
 		indexRes *elastic.IndexResponse
-		err      error
 	)
 
 	indexRequest := es.client.Index().Index(es.esIndex).Type(es.esType)
 
-	id, err := getId(doc)
+	id, err = getId(doc)
 	if err != nil {
 		return "", errors.Wrap(err, "method SaveOrUpdate() error")
 	}
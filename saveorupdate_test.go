@@ -9,7 +9,7 @@ import (
 )
 
 func TestEs_SaveOrUpdate(t *testing.T) {
-	es := setupSubTest("test_saveorupdate")
+	es := setupSubTest(t)
 
 	id, _ := es.SaveOrUpdate(context.Background(), map[string]interface{}{
 		"id":       "9seTXHoBNx091WJ2QCh8",
@@ -0,0 +1,20 @@
+package esutils_test
+
+import (
+	"net/http"
+
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+// handleSearch scripts searches of index on server, searches opening a scroll get docs in a single page and other
+// searches get search
+func handleSearch(server *esutilstest.Server, index string, search esutilstest.Response, docs ...map[string]interface{}) {
+	server.HandleFunc(http.MethodPost, "/"+index+"/_doc/_search", func(r esutilstest.Request) esutilstest.Response {
+		if r.Query.Get("scroll") != "" {
+			return esutilstest.ScrollResponse("s1", len(docs), docs...)
+		}
+		return search
+	})
+	server.Handle(http.MethodPost, "/_search/scroll", esutilstest.ScrollResponse("s1", len(docs)))
+	server.Handle(http.MethodDelete, "/_search/scroll", esutilstest.Response{Body: `{"succeeded":true}`})
+}
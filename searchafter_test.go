@@ -0,0 +1,42 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+func TestPage_searchAfter(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/test_page/_doc/_search", esutilstest.Response{
+		Body: `{"took":1,"hits":{"total":{"value":100,"relation":"eq"},"hits":[{"_id":"3","_source":{},"sort":[3]},{"_id":"4","_source":{},"sort":[4]}]}}`,
+	})
+
+	es := esutils.NewEs("test_page", esutils.WithUrls([]string{server.URL}))
+	paging := &esutils.Paging{
+		Sortby:      []esutils.Sort{{Field: "score", Ascending: true}},
+		Skip:        40,
+		Limit:       2,
+		SearchAfter: []interface{}{2},
+	}
+	pr, err := es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, pr.Page)
+	assert.True(t, pr.HasNextPage)
+	assert.Equal(t, []interface{}{float64(4)}, pr.SearchAfter)
+
+	paging.Limit = 3
+	pr, err = es.Page(context.Background(), paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, pr.Page)
+	assert.False(t, pr.HasNextPage)
+}
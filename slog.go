@@ -0,0 +1,46 @@
+//go:build go1.21
+// +build go1.21
+
+package esutils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LevelTrace is the slog level of request and response bodies logged by WithTraceLog
+const LevelTrace = slog.LevelDebug - 4
+
+// slogLogger adapts *slog.Logger to Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to logger, bodies logged by WithTraceLog are at LevelTrace
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) log(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+	if l.logger.Enabled(ctx, level) {
+		l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args...)
+}
+
+func (l slogLogger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, format, args...)
+}
+
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, format, args...)
+}
+
+func (l slogLogger) Tracef(format string, args ...interface{}) {
+	l.log(LevelTrace, format, args...)
+}
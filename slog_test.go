@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package esutils
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	logger.Infof("index %s created", "test")
+	logger.Debugf("hidden")
+	tracef(logger)("hidden %d", 1)
+	assert.Contains(t, buf.String(), "level=INFO")
+	assert.Contains(t, buf.String(), "index test created")
+	assert.NotContains(t, buf.String(), "hidden")
+
+	buf.Reset()
+	logger = NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: LevelTrace})))
+	tracef(logger)("body %s", "{}")
+	assert.Contains(t, buf.String(), "level=DEBUG-4")
+	assert.Contains(t, buf.String(), "body {}")
+}
@@ -0,0 +1,137 @@
+package esutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/copier"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// WithSlowQueryThreshold logs Es operations taking at least threshold at info level with the first request body,
+// e.g. the query of Page or Count. Bodies are only captured by the default client, an error is logged by NewEs if
+// the client is set by WithClient
+func WithSlowQueryThreshold(threshold time.Duration) EsOption {
+	return func(es *Es) {
+		es.slowQueryThreshold = threshold
+	}
+}
+
+// capturedRequest is the first request with body sent to es by an operation, or the first request if none has body
+type capturedRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+// plainBody returns raw body, decompressed if it is gzip encoded
+func plainBody(raw []byte, header http.Header) string {
+	if header.Get("Content-Encoding") == "gzip" {
+		if r, err := gzip.NewReader(bytes.NewReader(raw)); err == nil {
+			if plain, err := ioutil.ReadAll(r); err == nil {
+				return string(plain)
+			}
+		}
+	}
+	return string(raw)
+}
+
+// capturingTransport captures requests of the operation in request context for slow query log
+type capturingTransport struct {
+	next http.RoundTripper
+}
+
+func (t capturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	op := operationFrom(req.Context())
+	if op == nil {
+		return next.RoundTrip(req)
+	}
+	if captured := op.captured(); captured != nil && stringutils.IsNotEmpty(captured.body) {
+		return next.RoundTrip(req)
+	}
+	captured := &capturedRequest{
+		method: req.Method,
+		path:   req.URL.RequestURI(),
+	}
+	if req.Body != nil {
+		raw, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "call ReadAll() error")
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(raw))
+		captured.body = plainBody(raw, req.Header)
+	}
+	op.capture(captured)
+	return next.RoundTrip(req)
+}
+
+// logSlow logs op if it takes at least the slow query threshold
+func (e *Es) logSlow(op *operation, elapsed time.Duration) {
+	if e.slowQueryThreshold <= 0 || elapsed < e.slowQueryThreshold {
+		return
+	}
+	request := op.captured()
+	if request == nil {
+		e.logger.Infof("slow query: %s on index %s took %s, es took %dms", op.name, e.esIndex, elapsed, op.tookMs)
+		return
+	}
+	e.logger.Infof("slow query: %s on index %s took %s, es took %dms: %s %s %s", op.name, e.esIndex, elapsed, op.tookMs,
+		request.method, request.path, request.body)
+}
+
+// ExplainResult tells whether a doc matches and how its score is computed
+type ExplainResult struct {
+	Matched     bool                   `json:"matched"`
+	Explanation map[string]interface{} `json:"explanation"`
+}
+
+// Explain returns es explanation of the doc with id against the query of paging, useful for debugging relevance
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-explain.html
+func (es *Es) Explain(ctx context.Context, id string, paging *Paging) (result ExplainResult, err error) {
+	ctx, op, err := es.begin(ctx, "Explain", &Call{Paging: paging, IDs: []string{id}})
+	defer func() { err = op.end(result, err) }()
+	if err != nil {
+		return ExplainResult{}, err
+	}
+	paging = op.call.Paging
+	if paging == nil {
+		paging = &Paging{}
+	}
+	var zone *time.Location
+	if stringutils.IsNotEmpty(paging.Zone) {
+		zone, err = time.LoadLocation(paging.Zone)
+		if err != nil {
+			return ExplainResult{}, errors.Wrap(err, "call LoadLocation() error")
+		}
+	}
+	boolQuery, err := pagingQuery(paging, zone)
+	if err != nil {
+		return ExplainResult{}, errors.Wrap(err, "call pagingQuery() error")
+	}
+	res, err := es.client.Explain(es.esIndex, es.esType, id).Query(scoreQuery(boolQuery, paging.Relevance)).Do(ctx)
+	if err != nil {
+		return ExplainResult{}, errors.Wrap(err, "call Explain() error")
+	}
+	return ExplainResult{
+		Matched:     res.Matched,
+		Explanation: res.Explanation,
+	}, nil
+}
+
+// profileToMap converts profile of search result to map
+func profileToMap(profile interface{}) map[string]interface{} {
+	var ret map[string]interface{}
+	copier.DeepCopy(profile, &ret)
+	return ret
+}
@@ -0,0 +1,154 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+)
+
+func TestWithSlowQueryThreshold(t *testing.T) {
+	server := esutilstest.NewServer()
+	server.Handle(http.MethodPost, "/test_slow/_doc/_search", esutilstest.Response{
+		Body: `{"took":7,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`,
+	})
+	defer server.Close()
+
+	logger := &recordLogger{}
+	es := esutils.NewEs("test_slow", esutils.WithUrls([]string{server.URL}), esutils.WithLogger(logger), esutils.WithSlowQueryThreshold(time.Nanosecond))
+	_, err := es.Page(context.Background(), &esutils.Paging{
+		QueryConds: []esutils.QueryCond{
+			{
+				Pair: map[string][]interface{}{
+					"type": {"sport"},
+				},
+				QueryLogic: esutils.MUST,
+				QueryType:  esutils.TERMS,
+			},
+		},
+		Limit: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := slowLogs(logger)
+	if assert.Len(t, slow, 1) {
+		log := slow[0]
+		assert.True(t, strings.HasPrefix(log, "slow query: Page on index test_slow took "))
+		assert.Contains(t, log, "es took 7ms")
+		assert.Contains(t, log, "POST /test_slow/_doc/_search")
+		assert.Contains(t, log, `"sport"`)
+	}
+
+	logger = &recordLogger{}
+	es = esutils.NewEs("test_slow", esutils.WithUrls([]string{server.URL}), esutils.WithLogger(logger), esutils.WithSlowQueryThreshold(time.Hour))
+	_, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, slowLogs(logger))
+}
+
+func TestWithSlowQueryThreshold_client(t *testing.T) {
+	server := esutilstest.NewServer()
+	server.Handle(http.MethodPost, "/test_slow/_doc/_search", esutilstest.Response{
+		Body: `{"took":7,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`,
+	})
+	defer server.Close()
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+	es := esutils.NewEs("test_slow", esutils.WithClient(client), esutils.WithLogger(logger), esutils.WithSlowQueryThreshold(time.Nanosecond))
+	if assert.Len(t, logger.logs["error"], 1) {
+		assert.Contains(t, logger.logs["error"][0], "WithSlowQueryThreshold")
+	}
+	_, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := slowLogs(logger)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, "slow query: Page on index test_slow took ", logs[0][:len("slow query: Page on index test_slow took ")])
+		assert.NotContains(t, logs[0], "_search")
+	}
+}
+
+// slowLogs returns slow query logs of logger, the info logs of the elastic client are skipped
+func slowLogs(logger *recordLogger) []string {
+	var ret []string
+	for _, log := range logger.logs["info"] {
+		if strings.HasPrefix(log, "slow query: ") {
+			ret = append(ret, log)
+		}
+	}
+	return ret
+}
+
+func TestEs_Explain(t *testing.T) {
+	server := esutilstest.NewServer()
+	server.Handle(http.MethodGet, "/test_explain/_explain/1", esutilstest.Response{
+		Body: `{"_index":"test_explain","_id":"1","matched":true,"explanation":{"value":1.5,"description":"weight(text:ball)","details":[]}}`,
+	})
+	defer server.Close()
+
+	es := esutils.NewEs("test_explain", esutils.WithUrls([]string{server.URL}))
+	got, err := es.Explain(context.Background(), "1", &esutils.Paging{
+		QueryConds: []esutils.QueryCond{
+			{
+				Pair: map[string][]interface{}{
+					"text": {"ball"},
+				},
+				QueryLogic: esutils.SHOULD,
+				QueryType:  esutils.MATCHPHRASE,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"GET /test_explain/_explain/1"}, server.Paths())
+	assert.True(t, got.Matched)
+	assert.Equal(t, 1.5, got.Explanation["value"])
+	assert.Equal(t, "weight(text:ball)", got.Explanation["description"])
+}
+
+func TestPaging_Profile(t *testing.T) {
+	var profiled bool
+	server := esutilstest.NewServer()
+	server.HandleFunc(http.MethodPost, "/test_profile/_doc/_search", func(r esutilstest.Request) esutilstest.Response {
+		profiled = strings.Contains(string(r.Body), `"profile":true`)
+		if profiled {
+			return esutilstest.Response{
+				Body: `{"took":1,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]},"profile":{"shards":[{"id":"[n][test_profile][0]","searches":[],"aggregations":[]}]}}`,
+			}
+		}
+		return esutilstest.Response{Body: `{"took":1,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`}
+	})
+	defer server.Close()
+
+	es := esutils.NewEs("test_profile", esutils.WithUrls([]string{server.URL}))
+	pr, err := es.Page(context.Background(), &esutils.Paging{Limit: 1, Profile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, profiled)
+	if assert.NotNil(t, pr.Profile) {
+		shards := pr.Profile["shards"].([]interface{})
+		assert.Equal(t, "[n][test_profile][0]", shards[0].(map[string]interface{})["id"])
+	}
+
+	pr, err = es.Page(context.Background(), &esutils.Paging{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, profiled)
+	assert.Nil(t, pr.Profile)
+}
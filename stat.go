@@ -10,9 +10,14 @@ import (
 )
 
 // Stat aggr only accept map[string]interface{} or elastic.Aggregation
-func (es *Es) Stat(ctx context.Context, paging *Paging, aggr interface{}) (map[string]interface{}, error) {
+func (es *Es) Stat(ctx context.Context, paging *Paging, aggr interface{}) (result map[string]interface{}, err error) {
+	ctx, op, err := es.begin(ctx, "Stat", &Call{Paging: paging, Body: aggr})
+	defer func() { err = op.end(result, err) }()
+	if err != nil {
+		return nil, err
+	}
+	paging = op.call.Paging
 	var (
-		err          error
 		sr           *elastic.SearchResult
 		statQueryMap map[string]interface{}
 		src          elastic.Query
@@ -26,7 +31,9 @@ func (es *Es) Stat(ctx context.Context, paging *Paging, aggr interface{}) (map[s
 				return nil, errors.Wrap(err, "call LoadLocation() error")
 			}
 		}
-		src = query(paging.StartDate, paging.EndDate, paging.DateField, paging.QueryConds, zone)
+		if src, err = pagingQuery(paging, zone); err != nil {
+			return nil, errors.Wrap(err, "call pagingQuery() error")
+		}
 	}
 
 	searchService := es.client.Search().Index(es.esIndex).Type(es.esType)
@@ -55,7 +62,7 @@ func (es *Es) Stat(ctx context.Context, paging *Paging, aggr interface{}) (map[s
 			return nil, errors.Wrap(err, "call Search() error")
 		}
 	}
-	var result map[string]interface{}
+	recordSearch(ctx, sr)
 	copier.DeepCopy(sr.Aggregations, &result)
 	return result, nil
 }
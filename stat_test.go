@@ -10,7 +10,7 @@ import (
 )
 
 func TestEs_Stat(t *testing.T) {
-	es := setupSubTest("test_stat")
+	es := setupSubTest(t)
 
 	jaggr := `{
         "groupBy": {
@@ -32,7 +32,7 @@ func TestEs_Stat(t *testing.T) {
 }
 
 func TestEs_Stat2(t *testing.T) {
-	es := setupSubTest("test_stat1")
+	es := setupSubTest(t)
 
 	aggr := elastic.NewTermsAggregation().Field("type.keyword").Size(9999).ExecutionHint("map").MinDocCount(1)
 	ret, _ := es.Stat(context.Background(), nil, aggr)
@@ -43,7 +43,7 @@ func TestEs_Stat2(t *testing.T) {
 }
 
 func TestEs_Stat3(t *testing.T) {
-	es := setupSubTest("test_stat")
+	es := setupSubTest(t)
 
 	jaggr := `{
         "groupBy": {
@@ -0,0 +1,216 @@
+package esutils
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+const suggesterName = "suggest"
+
+// Suggestion is an option returned by suggesters
+type Suggestion struct {
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+	// Freq is the doc frequency of the term, only for term suggester
+	Freq int `json:"freq"`
+	// Highlighted is the text with corrected terms highlighted, only for phrase suggester with PreTag and PostTag
+	Highlighted string `json:"highlighted"`
+	// ID and Source are the suggested doc, only for completion suggester
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+// TermSuggestion holds suggestions for a token of the text given to SuggestTerm
+type TermSuggestion struct {
+	Token   string       `json:"token"`
+	Offset  int          `json:"offset"`
+	Length  int          `json:"length"`
+	Options []Suggestion `json:"options"`
+}
+
+// CompletionOptions defines options of SuggestCompletion
+type CompletionOptions struct {
+	Size           int  `json:"size"`
+	SkipDuplicates bool `json:"skipDuplicates"`
+	// Fuzziness enables typo tolerant prefix matching, e.g. AUTO, 1 or 2
+	Fuzziness string `json:"fuzziness"`
+	// Contexts filters suggestions by category contexts keyed by context name
+	Contexts map[string][]string `json:"contexts"`
+	// GeoContexts filters suggestions by geo contexts keyed by context name
+	GeoContexts map[string]GeoPoint `json:"geoContexts"`
+}
+
+// TermSuggestOptions defines options of SuggestTerm
+type TermSuggestOptions struct {
+	Size int `json:"size"`
+	// SuggestMode is one of missing, popular and always, default is missing
+	SuggestMode   string `json:"suggestMode"`
+	MaxEdits      int    `json:"maxEdits"`
+	PrefixLength  int    `json:"prefixLength"`
+	MinWordLength int    `json:"minWordLength"`
+}
+
+// PhraseSuggestOptions defines options of SuggestPhrase
+type PhraseSuggestOptions struct {
+	Size int `json:"size"`
+	// GramSize should be the max shingle size of the field if it is analyzed with shingle filter
+	GramSize   int     `json:"gramSize"`
+	MaxErrors  float64 `json:"maxErrors"`
+	Confidence float64 `json:"confidence"`
+	PreTag     string  `json:"preTag"`
+	PostTag    string  `json:"postTag"`
+}
+
+func (es *Es) suggest(ctx context.Context, suggester elastic.Suggester) ([]elastic.SearchSuggestion, error) {
+	sr, err := es.client.Search().Index(es.esIndex).Type(es.esType).Suggester(suggester).Size(0).Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "call Search() error")
+	}
+	recordSearch(ctx, sr)
+	return sr.Suggest[suggesterName], nil
+}
+
+func toSuggestion(option elastic.SearchSuggestionOption) Suggestion {
+	s := Suggestion{
+		Text:        option.Text,
+		Score:       option.Score,
+		Freq:        option.Freq,
+		Highlighted: option.Highlighted,
+		ID:          option.Id,
+	}
+	if s.Score == 0 {
+		s.Score = option.ScoreUnderscore
+	}
+	if len(option.Source) > 0 {
+		json.Unmarshal(option.Source, &s.Source)
+	}
+	return s
+}
+
+// SuggestCompletion returns suggestions of completion field starting with prefix for type-ahead
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-suggesters.html#completion-suggester
+func (es *Es) SuggestCompletion(ctx context.Context, field, prefix string, opts *CompletionOptions) (ret []Suggestion, err error) {
+	ctx, op, err := es.begin(ctx, "SuggestCompletion", nil)
+	defer func() { err = op.end(ret, err) }()
+	if err != nil {
+		return nil, err
+	}
+	suggester := elastic.NewCompletionSuggester(suggesterName).Field(field).Prefix(prefix)
+	if opts != nil {
+		if opts.Size > 0 {
+			suggester.Size(opts.Size)
+		}
+		if opts.SkipDuplicates {
+			suggester.SkipDuplicates(true)
+		}
+		if stringutils.IsNotEmpty(opts.Fuzziness) {
+			suggester.Fuzziness(opts.Fuzziness)
+		}
+		for name, values := range opts.Contexts {
+			suggester.ContextQuery(elastic.NewSuggesterCategoryQuery(name, values...))
+		}
+		for name, point := range opts.GeoContexts {
+			suggester.ContextQuery(elastic.NewSuggesterGeoQuery(name, elastic.GeoPointFromLatLon(point.Lat, point.Lon)))
+		}
+	}
+	results, err := es.suggest(ctx, suggester)
+	if err != nil {
+		return nil, errors.Wrap(err, "call suggest() error")
+	}
+	ret = make([]Suggestion, 0)
+	for _, result := range results {
+		for _, option := range result.Options {
+			ret = append(ret, toSuggestion(option))
+		}
+	}
+	return ret, nil
+}
+
+// SuggestTerm returns corrections for each token of text, e.g. for "did you mean"
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-suggesters.html#term-suggester
+func (es *Es) SuggestTerm(ctx context.Context, field, text string, opts *TermSuggestOptions) (ret []TermSuggestion, err error) {
+	ctx, op, err := es.begin(ctx, "SuggestTerm", nil)
+	defer func() { err = op.end(ret, err) }()
+	if err != nil {
+		return nil, err
+	}
+	suggester := elastic.NewTermSuggester(suggesterName).Field(field).Text(text)
+	if opts != nil {
+		if opts.Size > 0 {
+			suggester.Size(opts.Size)
+		}
+		if stringutils.IsNotEmpty(opts.SuggestMode) {
+			suggester.SuggestMode(opts.SuggestMode)
+		}
+		if opts.MaxEdits > 0 {
+			suggester.MaxEdits(opts.MaxEdits)
+		}
+		if opts.PrefixLength > 0 {
+			suggester.PrefixLength(opts.PrefixLength)
+		}
+		if opts.MinWordLength > 0 {
+			suggester.MinWordLength(opts.MinWordLength)
+		}
+	}
+	results, err := es.suggest(ctx, suggester)
+	if err != nil {
+		return nil, errors.Wrap(err, "call suggest() error")
+	}
+	ret = make([]TermSuggestion, 0, len(results))
+	for _, result := range results {
+		ts := TermSuggestion{
+			Token:   result.Text,
+			Offset:  result.Offset,
+			Length:  result.Length,
+			Options: make([]Suggestion, 0, len(result.Options)),
+		}
+		for _, option := range result.Options {
+			ts.Options = append(ts.Options, toSuggestion(option))
+		}
+		ret = append(ret, ts)
+	}
+	return ret, nil
+}
+
+// SuggestPhrase returns corrections of the whole text, e.g. for "did you mean"
+// https://www.elastic.co/guide/en/elasticsearch/reference/7.17/search-suggesters.html#phrase-suggester
+func (es *Es) SuggestPhrase(ctx context.Context, field, text string, opts *PhraseSuggestOptions) (ret []Suggestion, err error) {
+	ctx, op, err := es.begin(ctx, "SuggestPhrase", nil)
+	defer func() { err = op.end(ret, err) }()
+	if err != nil {
+		return nil, err
+	}
+	suggester := elastic.NewPhraseSuggester(suggesterName).Field(field).Text(text)
+	if opts != nil {
+		if opts.Size > 0 {
+			suggester.Size(opts.Size)
+		}
+		if opts.GramSize > 0 {
+			suggester.GramSize(opts.GramSize)
+		}
+		if opts.MaxErrors > 0 {
+			suggester.MaxErrors(opts.MaxErrors)
+		}
+		if opts.Confidence > 0 {
+			suggester.Confidence(opts.Confidence)
+		}
+		if stringutils.IsNotEmpty(opts.PreTag) || stringutils.IsNotEmpty(opts.PostTag) {
+			suggester.Highlight(opts.PreTag, opts.PostTag)
+		}
+	}
+	results, err := es.suggest(ctx, suggester)
+	if err != nil {
+		return nil, errors.Wrap(err, "call suggest() error")
+	}
+	ret = make([]Suggestion, 0)
+	for _, result := range results {
+		for _, option := range result.Options {
+			ret = append(ret, toSuggestion(option))
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,187 @@
+package esutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMapping_suggest(t *testing.T) {
+	got := NewMapping(MappingPayload{
+		Base{
+			Index: "test_suggest",
+		},
+		[]Field{
+			{
+				Name: "suggest",
+				Type: COMPLETION,
+				Contexts: []CompletionContext{
+					{
+						Name: "category",
+						Type: "category",
+						Path: "type",
+					},
+					{
+						Name:      "location",
+						Type:      "geo",
+						Precision: 4,
+					},
+				},
+			},
+			{
+				Name:           "title",
+				Type:           SEARCHASYOUTYPE,
+				MaxShingleSize: 2,
+			},
+		},
+	})
+	parsed, err := gabs.ParseJSON([]byte(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{
+  "suggest": {
+    "type": "completion",
+    "contexts": [
+      {"name": "category", "type": "category", "path": "type"},
+      {"name": "location", "type": "geo", "precision": 4}
+    ]
+  },
+  "title": {"type": "search_as_you_type", "max_shingle_size": 2}
+}`, parsed.Search("mappings", "_doc", "properties").String())
+}
+
+func Test_boolPrefix_query(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxShingleSize int
+		want           string
+	}{
+		{
+			name: "default",
+			want: `{"bool":{"must":{"multi_match":{"boost":2,"fields":["title","title._2gram","title._3gram"],"query":"quick br","type":"bool_prefix"}}}}`,
+		},
+		{
+			name:           "2",
+			maxShingleSize: 2,
+			want:           `{"bool":{"must":{"multi_match":{"boost":2,"fields":["title","title._2gram"],"query":"quick br","type":"bool_prefix"}}}}`,
+		},
+		{
+			name:           "4",
+			maxShingleSize: 4,
+			want:           `{"bool":{"must":{"multi_match":{"boost":2,"fields":["title","title._2gram","title._3gram","title._4gram"],"query":"quick br","type":"bool_prefix"}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryConds := []QueryCond{
+				{
+					Pair: map[string][]interface{}{
+						"title": {"quick br"},
+					},
+					QueryLogic:     MUST,
+					QueryType:      BOOLPREFIX,
+					Boost:          2,
+					MaxShingleSize: tt.maxShingleSize,
+				},
+			}
+			src, err := query("", "", "", queryConds, "", loc).Source()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.JSONEq(t, tt.want, gabs.Wrap(src).String())
+		})
+	}
+}
+
+func TestEs_Suggest(t *testing.T) {
+	es := setupIndex(t, NewMapping(MappingPayload{
+		Fields: []Field{
+			{
+				Name: "suggest",
+				Type: COMPLETION,
+				Contexts: []CompletionContext{
+					{
+						Name: "category",
+						Type: "category",
+						Path: "type",
+					},
+				},
+			},
+			{
+				Name: "type",
+				Type: KEYWORD,
+			},
+			{
+				Name: "title",
+				Type: SEARCHASYOUTYPE,
+			},
+		},
+	}),
+		map[string]interface{}{
+			"id":      "1",
+			"type":    "phone",
+			"title":   "apple iphone charger",
+			"suggest": "apple iphone",
+		},
+		map[string]interface{}{
+			"id":      "2",
+			"type":    "laptop",
+			"title":   "apple macbook pro",
+			"suggest": "apple macbook",
+		},
+	)
+
+	completions, err := es.SuggestCompletion(context.Background(), "suggest", "app", &CompletionOptions{
+		Contexts: map[string][]string{
+			"category": {"laptop"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, completions, 1) {
+		assert.Equal(t, "apple macbook", completions[0].Text)
+		assert.Equal(t, "2", completions[0].ID)
+		assert.Equal(t, "laptop", completions[0].Source["type"])
+	}
+
+	terms, err := es.SuggestTerm(context.Background(), "title", "iphnoe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, terms, 1) && assert.NotEmpty(t, terms[0].Options) {
+		assert.Equal(t, "iphone", terms[0].Options[0].Text)
+	}
+
+	phrases, err := es.SuggestPhrase(context.Background(), "title", "aple iphone", &PhraseSuggestOptions{
+		PreTag:  "<em>",
+		PostTag: "</em>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotEmpty(t, phrases) {
+		assert.Equal(t, "apple iphone", phrases[0].Text)
+		assert.Equal(t, "<em>apple</em> iphone", phrases[0].Highlighted)
+	}
+
+	page, err := es.Page(context.Background(), &Paging{
+		Limit: 10,
+		QueryConds: []QueryCond{
+			{
+				Pair: map[string][]interface{}{
+					"title": {"apple mac"},
+				},
+				QueryLogic: MUST,
+				QueryType:  BOOLPREFIX,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, page.Total)
+}
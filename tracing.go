@@ -0,0 +1,79 @@
+package esutils
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/wubin1989/go-esutils/v2"
+
+// WithTracerProvider enables OpenTelemetry tracing, every Es operation like Page or BulkSaveOrUpdate has a span and
+// every http request of the default client has a child span. Requests of the client of WithClient have no spans and
+// an error is logged by NewEs. Tracing is a no-op by default
+func WithTracerProvider(provider trace.TracerProvider) EsOption {
+	return func(es *Es) {
+		es.tracerProvider = provider
+	}
+}
+
+func (e *Es) tracer() trace.Tracer {
+	provider := e.tracerProvider
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// startSpan starts the span of operation op
+func (e *Es) startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return e.tracer().Start(ctx, "esutils."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "elasticsearch"),
+			attribute.String("db.operation", op),
+			attribute.String("esutils.index", e.esIndex),
+		))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingTransport starts a span for every http request to es
+type tracingTransport struct {
+	next   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.Redacted()),
+			attribute.String("net.peer.name", req.URL.Hostname()),
+		))
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		endSpan(span, err)
+		return resp, err
+	}
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
@@ -0,0 +1,101 @@
+package esutils_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	esutils "github.com/wubin1989/go-esutils/v2"
+	"github.com/wubin1989/go-esutils/v2/esutilstest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/test_tracing/_doc/_search", esutilstest.Response{
+		Body: `{"took":7,"hits":{"total":{"value":2,"relation":"eq"},"hits":[{"_id":"1","_source":{"name":"a"}},{"_id":"2","_source":{"name":"b"}}]}}`,
+	})
+	server.Handle(http.MethodPost, "/test_tracing/_doc/_bulk", esutilstest.Response{
+		Body: `{"took":3,"errors":true,"items":[{"index":{"_id":"1","status":201}},{"index":{"_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`,
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	es := esutils.NewEs("test_tracing", esutils.WithUrls([]string{server.URL}), esutils.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+
+	got, err := es.Page(context.Background(), &esutils.Paging{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, got.Total)
+	err = es.BulkSaveOrUpdate(context.Background(), []interface{}{
+		map[string]interface{}{"id": "1"},
+		map[string]interface{}{"id": "2"},
+	})
+	assert.EqualError(t, err, "failed to parse")
+
+	spans := recorder.Ended()
+	var page, bulk sdktrace.ReadOnlySpan
+	var requests int
+	for _, span := range spans {
+		switch span.Name() {
+		case "esutils.Page":
+			page = span
+		case "esutils.BulkSaveOrUpdate":
+			bulk = span
+		default:
+			if strings.HasPrefix(span.Name(), "HTTP ") {
+				requests++
+			}
+		}
+	}
+	if assert.NotNil(t, page) {
+		attrs := spanAttrs(page)
+		assert.Equal(t, "Page", attrs["db.operation"].AsString())
+		assert.Equal(t, "test_tracing", attrs["esutils.index"].AsString())
+		assert.Equal(t, int64(7), attrs["esutils.took_ms"].AsInt64())
+		assert.Equal(t, int64(2), attrs["esutils.hits"].AsInt64())
+		assert.Equal(t, codes.Unset, page.Status().Code)
+	}
+	if assert.NotNil(t, bulk) {
+		attrs := spanAttrs(bulk)
+		assert.Equal(t, int64(2), attrs["esutils.bulk.items"].AsInt64())
+		assert.Equal(t, int64(1), attrs["esutils.bulk.failed"].AsInt64())
+		assert.Equal(t, codes.Error, bulk.Status().Code)
+		assert.Len(t, bulk.Events(), 1)
+	}
+	for _, span := range spans {
+		if strings.HasPrefix(span.Name(), "HTTP ") && span.Parent().SpanID() == page.SpanContext().SpanID() {
+			assert.Equal(t, int64(200), spanAttrs(span)["http.status_code"].AsInt64())
+		}
+	}
+	assert.GreaterOrEqual(t, requests, 2)
+}
+
+func TestWithTracerProvider_client(t *testing.T) {
+	server := esutilstest.NewServer()
+	defer server.Close()
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+	esutils.NewEs("test_tracing", esutils.WithClient(client), esutils.WithLogger(logger), esutils.WithTracerProvider(sdktrace.NewTracerProvider()))
+	if assert.Len(t, logger.logs["error"], 1) {
+		assert.Contains(t, logger.logs["error"][0], "WithTracerProvider")
+	}
+}
@@ -0,0 +1,406 @@
+package esutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/unionj-cloud/go-doudou/toolkit/stringutils"
+)
+
+// ValidationError describes a problem of a query condition found by Validate
+type ValidationError struct {
+	// Path locates the condition in paging, e.g. queryConds[1].children[0]
+	Path      string    `json:"path"`
+	Field     string    `json:"field"`
+	QueryType queryType `json:"queryType"`
+	Msg       string    `json:"msg"`
+}
+
+func (e ValidationError) Error() string {
+	if stringutils.IsEmpty(e.Field) {
+		return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+	}
+	return fmt.Sprintf("%s: field %q: %s", e.Path, e.Field, e.Msg)
+}
+
+// ValidationErrors collects all problems found by Validate
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func isNumericType(fieldType string) bool {
+	switch fieldType {
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		return true
+	}
+	return false
+}
+
+func isStringType(fieldType string) bool {
+	switch fieldType {
+	case "keyword", "constant_keyword", "wildcard", "text", "match_only_text", "search_as_you_type":
+		return true
+	}
+	return false
+}
+
+func isKeywordType(fieldType string) bool {
+	switch fieldType {
+	case "keyword", "constant_keyword", "wildcard":
+		return true
+	}
+	return false
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+type validator struct {
+	fieldTypes map[string]string
+	errs       ValidationErrors
+}
+
+func (v *validator) add(path, field string, qt queryType, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{
+		Path:      path,
+		Field:     field,
+		QueryType: qt,
+		Msg:       fmt.Sprintf(format, args...),
+	})
+}
+
+// fieldType returns the type of field and reports missing fields
+func (v *validator) fieldType(path, field string, qt queryType) (string, bool) {
+	fieldType, ok := v.fieldTypes[field]
+	if !ok {
+		v.add(path, field, qt, "field not found in mapping")
+	}
+	return fieldType, ok
+}
+
+func (v *validator) validateCond(path string, qc QueryCond) {
+	switch qc.QueryLogic {
+	case SHOULD, MUST, MUSTNOT, FILTER:
+	default:
+		v.add(path, "", qc.QueryType, "unknown query logic %d", qc.QueryLogic)
+	}
+	if qc.Boost < 0 {
+		v.add(path, "", qc.QueryType, "boost must not be negative, got %v", qc.Boost)
+	} else if qc.Boost > 0 && len(qc.Children) == 0 {
+		switch qc.QueryType {
+		case EXISTS, GEODISTANCE, GEOBOUNDINGBOX, GEOPOLYGON:
+			v.add(path, "", qc.QueryType, "boost is not supported by this query type")
+		}
+	}
+	if stringutils.IsNotEmpty(qc.MinimumShouldMatch) && len(qc.Children) == 0 {
+		v.add(path, "", qc.QueryType, "minimum_should_match is only supported on conditions with children")
+	}
+	if qc.QueryType == FUZZY && stringutils.IsNotEmpty(qc.Fuzziness) {
+		switch {
+		case qc.Fuzziness == "0", qc.Fuzziness == "1", qc.Fuzziness == "2", strings.HasPrefix(qc.Fuzziness, "AUTO"):
+		default:
+			v.add(path, "", qc.QueryType, "fuzziness must be AUTO, 0, 1 or 2, got %q", qc.Fuzziness)
+		}
+	}
+	if qc.QueryType == NESTED {
+		if stringutils.IsEmpty(qc.Path) {
+			v.add(path, "", qc.QueryType, "nested query must have path")
+		} else if fieldType, ok := v.fieldType(path, qc.Path, qc.QueryType); ok && fieldType != "nested" {
+			v.add(path, qc.Path, qc.QueryType, "nested query is not supported on %s field", fieldType)
+		}
+		switch qc.ScoreMode {
+		case "", "avg", "max", "min", "sum", "none":
+		default:
+			v.add(path, qc.Path, qc.QueryType, "unknown score mode %q", qc.ScoreMode)
+		}
+	}
+	if len(qc.Children) > 0 {
+		for i, child := range qc.Children {
+			v.validateCond(fmt.Sprintf("%s.children[%d]", path, i), child)
+		}
+		return
+	}
+	fields := make([]string, 0, len(qc.Pair))
+	for field := range qc.Pair {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		v.validateField(path, qc.QueryType, field, qc.Pair[field])
+	}
+}
+
+func (v *validator) validateField(path string, qt queryType, field string, value []interface{}) {
+	if qt != EXISTS && len(value) == 0 {
+		v.add(path, field, qt, "no value given, the condition would be ignored")
+		return
+	}
+	var (
+		fieldType string
+		ok        bool
+	)
+	if qt != IDS {
+		fieldType, ok = v.fieldType(path, field, qt)
+	}
+	switch qt {
+	case TERMS:
+		for _, item := range value {
+			if !isScalar(item) {
+				v.add(path, field, qt, "terms value must be a string, number or boolean, got %T", item)
+			}
+		}
+		if ok && fieldType == "text" {
+			if sub, ok := v.fieldTypes[field+".keyword"]; ok && isKeywordType(sub) {
+				v.add(path, field, qt, "terms query on text field matches analyzed tokens only, use %q instead", field+".keyword")
+			} else {
+				v.add(path, field, qt, "terms query on text field matches analyzed tokens only, use a keyword field or MATCHPHRASE")
+			}
+		}
+	case TERM:
+		if !isScalar(value[0]) {
+			v.add(path, field, qt, "term value must be a string, number or boolean, got %T", value[0])
+		}
+		if ok && fieldType == "text" {
+			v.add(path, field, qt, "term query on text field matches analyzed tokens only, use a keyword field or MATCHPHRASE")
+		}
+	case FUZZY, REGEXP:
+		if s, isString := value[0].(string); !isString || stringutils.IsEmpty(s) {
+			v.add(path, field, qt, "value must be a non-empty string, got %T", value[0])
+		}
+		if ok && !isStringType(fieldType) {
+			v.add(path, field, qt, "fuzzy and regexp queries are not supported on %s field", fieldType)
+		}
+	case BOOLPREFIX:
+		if s, isString := value[0].(string); !isString || stringutils.IsEmpty(s) {
+			v.add(path, field, qt, "value must be a non-empty string, got %T", value[0])
+		}
+		if ok && fieldType != string(SEARCHASYOUTYPE) {
+			v.add(path, field, qt, "bool_prefix query is only supported on search_as_you_type field, got %s field", fieldType)
+		}
+	case IDS:
+		if field != "_id" {
+			v.add(path, field, qt, "ids query must use _id as key of Pair")
+		}
+		for _, item := range value {
+			if !isScalar(item) {
+				v.add(path, field, qt, "id must be a string or number, got %T", item)
+			}
+		}
+	case MATCHPHRASE:
+		for _, item := range value {
+			if _, isString := item.(string); !isString {
+				v.add(path, field, qt, "match_phrase value must be a string, got %T", item)
+			}
+		}
+		if ok && !isStringType(fieldType) {
+			v.add(path, field, qt, "match_phrase query is not supported on %s field", fieldType)
+		}
+	case RANGE:
+		params, isMap := value[0].(map[string]interface{})
+		if !isMap {
+			v.add(path, field, qt, "range value must be a map with from, to, include_lower and include_upper keys, got %T", value[0])
+			break
+		}
+		if params["from"] == nil && params["to"] == nil {
+			v.add(path, field, qt, "range value must have from or to")
+		}
+		for _, key := range []string{"include_lower", "include_upper"} {
+			if params[key] != nil {
+				if _, isBool := params[key].(bool); !isBool {
+					v.add(path, field, qt, "%s must be a boolean, got %T", key, params[key])
+				}
+			}
+		}
+		if ok && !isNumericType(fieldType) && fieldType != "date" && fieldType != "date_nanos" && !isKeywordType(fieldType) && fieldType != "ip" {
+			v.add(path, field, qt, "range query is not supported on %s field", fieldType)
+		}
+	case PREFIX, WILDCARD:
+		if s, isString := value[0].(string); !isString || stringutils.IsEmpty(s) {
+			v.add(path, field, qt, "value must be a non-empty string, got %T", value[0])
+		}
+		if ok && !isStringType(fieldType) {
+			v.add(path, field, qt, "prefix and wildcard queries are not supported on %s field", fieldType)
+		}
+	case GEODISTANCE:
+		params, isMap := value[0].(map[string]interface{})
+		if !isMap {
+			v.add(path, field, qt, "geo distance value must be a map with lat, lon and distance keys, got %T", value[0])
+			break
+		}
+		if _, isPoint := toGeoPoint(params); !isPoint {
+			v.add(path, field, qt, "geo distance value must have numeric lat and lon")
+		}
+		if distance, _ := params["distance"].(string); stringutils.IsEmpty(distance) {
+			v.add(path, field, qt, "geo distance value must have distance like 10km")
+		}
+		if ok && fieldType != "geo_point" && fieldType != "geo_shape" {
+			v.add(path, field, qt, "geo distance query is not supported on %s field", fieldType)
+		}
+	case GEOBOUNDINGBOX:
+		params, isMap := value[0].(map[string]interface{})
+		if !isMap {
+			v.add(path, field, qt, "geo bounding box value must be a map with top_left and bottom_right keys, got %T", value[0])
+			break
+		}
+		for _, key := range []string{"top_left", "bottom_right"} {
+			if _, isPoint := toGeoPoint(params[key]); !isPoint {
+				v.add(path, field, qt, "%s must be a geo point", key)
+			}
+		}
+		if ok && fieldType != "geo_point" && fieldType != "geo_shape" {
+			v.add(path, field, qt, "geo bounding box query is not supported on %s field", fieldType)
+		}
+	case GEOPOLYGON:
+		if len(value) < 3 {
+			v.add(path, field, qt, "geo polygon must have at least 3 points")
+		}
+		for i, item := range value {
+			if _, isPoint := toGeoPoint(item); !isPoint {
+				v.add(path, field, qt, "point %d must be a geo point, got %T", i, item)
+			}
+		}
+		if ok && fieldType != "geo_point" {
+			v.add(path, field, qt, "geo polygon query is not supported on %s field", fieldType)
+		}
+	case EXISTS:
+	default:
+		v.add(path, field, qt, "unknown query type %d", qt)
+	}
+}
+
+func (v *validator) validateDateField(path, field string) {
+	if fieldType, ok := v.fieldType(path, field, 0); ok && fieldType != "date" && fieldType != "date_nanos" {
+		v.add(path, field, 0, "date range is not supported on %s field", fieldType)
+	}
+}
+
+func (v *validator) validateRelevance(relevance *Relevance) {
+	switch relevance.ScoreMode {
+	case "", "multiply", "sum", "avg", "first", "max", "min":
+	default:
+		v.add("relevance", "", 0, "unknown score mode %q", relevance.ScoreMode)
+	}
+	switch relevance.BoostMode {
+	case "", "multiply", "replace", "sum", "avg", "max", "min":
+	default:
+		v.add("relevance", "", 0, "unknown boost mode %q", relevance.BoostMode)
+	}
+	for i, f := range relevance.Functions {
+		path := fmt.Sprintf("relevance.functions[%d]", i)
+		for j, qc := range f.Filter {
+			v.validateCond(fmt.Sprintf("%s.filter[%d]", path, j), qc)
+		}
+		var kinds int
+		if f.FieldValueFactor != nil {
+			kinds++
+			if fieldType, ok := v.fieldType(path, f.FieldValueFactor.Field, 0); ok && !isNumericType(fieldType) {
+				v.add(path, f.FieldValueFactor.Field, 0, "field_value_factor is not supported on %s field", fieldType)
+			}
+		}
+		if f.Decay != nil {
+			kinds++
+			switch f.Decay.Type {
+			case "", "gauss", "exp", "linear":
+			default:
+				v.add(path, f.Decay.Field, 0, "unknown decay function %q", f.Decay.Type)
+			}
+			if f.Decay.Scale == nil {
+				v.add(path, f.Decay.Field, 0, "decay function must have scale")
+			}
+			if fieldType, ok := v.fieldType(path, f.Decay.Field, 0); ok && !isNumericType(fieldType) && fieldType != "date" && fieldType != "date_nanos" && fieldType != "geo_point" {
+				v.add(path, f.Decay.Field, 0, "decay function is not supported on %s field", fieldType)
+			}
+		}
+		if f.Script != nil {
+			kinds++
+			if stringutils.IsEmpty(f.Script.Source) {
+				v.add(path, "", 0, "script must have source")
+			}
+		}
+		if kinds > 1 {
+			v.add(path, "", 0, "only one of fieldValueFactor, decay and script can be set")
+		} else if kinds == 0 && f.Weight <= 0 {
+			v.add(path, "", 0, "function must have fieldValueFactor, decay, script or weight")
+		}
+	}
+}
+
+// Validate checks paging against the index mapping before sending it to es: fields of query conditions, DateField,
+// DateRanges and Sortby must exist, query types must suit field types, and values and dates must have the right shape.
+// All problems are returned at once as ValidationErrors, other errors are returned if the mapping cannot be fetched.
+func (es *Es) Validate(ctx context.Context, paging *Paging) error {
+	if paging == nil {
+		return nil
+	}
+	fieldTypes, err := es.GetFieldTypes(ctx)
+	if err != nil {
+		return errors.Wrap(err, "call GetFieldTypes() error")
+	}
+	v := &validator{
+		fieldTypes: fieldTypes,
+	}
+	if stringutils.IsNotEmpty(paging.DateField) {
+		v.validateDateField("dateField", paging.DateField)
+	}
+	if r, ok := paging.dateRange(); ok {
+		if err := r.check(); err != nil {
+			v.add("dateField", paging.DateField, 0, err.Error())
+		}
+	}
+	for i, r := range paging.DateRanges {
+		path := fmt.Sprintf("dateRanges[%d]", i)
+		if err := r.check(); err != nil {
+			v.add(path, r.Field, 0, err.Error())
+		}
+		if stringutils.IsNotEmpty(r.Field) {
+			v.validateDateField(path, r.Field)
+		}
+	}
+	for i, qc := range paging.QueryConds {
+		v.validateCond(fmt.Sprintf("queryConds[%d]", i), qc)
+	}
+	if paging.Relevance != nil {
+		v.validateRelevance(paging.Relevance)
+	}
+	if paging.Collapse != nil {
+		if fieldType, ok := v.fieldType("collapse", paging.Collapse.Field, 0); ok && !isKeywordType(fieldType) && !isNumericType(fieldType) {
+			v.add("collapse", paging.Collapse.Field, 0, "collapsing is not supported on %s field, use a keyword or numeric field", fieldType)
+		}
+		if paging.Limit < 0 || paging.Limit > 10000 {
+			v.add("collapse", paging.Collapse.Field, 0, "collapsing is not supported when fetching all docs, set limit between 0 and 10000")
+		}
+	}
+	for i, s := range paging.Sortby {
+		path := fmt.Sprintf("sortby[%d]", i)
+		if strings.HasPrefix(s.Field, "_") {
+			continue
+		}
+		fieldType, ok := v.fieldType(path, s.Field, 0)
+		if !ok {
+			continue
+		}
+		if s.GeoPoint != nil && fieldType != "geo_point" {
+			v.add(path, s.Field, 0, "geo distance sorting is not supported on %s field", fieldType)
+		} else if s.GeoPoint == nil && fieldType == "text" {
+			v.add(path, s.Field, 0, "sorting on text field is not supported, use a keyword field")
+		}
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
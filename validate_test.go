@@ -0,0 +1,312 @@
+package esutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEs_Validate(t *testing.T) {
+	es := setupSubTest(t)
+	tests := []struct {
+		name    string
+		paging  *Paging
+		want    ValidationErrors
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			paging: &Paging{
+				DateField: "createAt",
+				StartDate: "2020-06-01",
+				EndDate:   "2020-07-10",
+				QueryConds: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"text": {"考生"},
+						},
+						QueryLogic: SHOULD,
+						QueryType:  MATCHPHRASE,
+					},
+					{
+						Pair: map[string][]interface{}{
+							"type.keyword": {"education", "sport"},
+						},
+						QueryLogic: MUST,
+						QueryType:  TERMS,
+					},
+					{
+						Pair: map[string][]interface{}{
+							"_id": {"9seTXHoBNx091WJ2QCh5"},
+						},
+						QueryLogic: MUSTNOT,
+						QueryType:  IDS,
+					},
+				},
+				Sortby: []Sort{
+					{
+						Field: "createAt",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fuzzy and ids",
+			paging: &Paging{
+				QueryConds: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"createAt": {"2020"},
+						},
+						QueryLogic: MUST,
+						QueryType:  FUZZY,
+						Fuzziness:  "3",
+					},
+					{
+						Pair: map[string][]interface{}{
+							"id": {"9seTXHoBNx091WJ2QCh5"},
+						},
+						QueryLogic: MUST,
+						QueryType:  IDS,
+					},
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path:      "queryConds[0]",
+					QueryType: FUZZY,
+					Msg:       `fuzziness must be AUTO, 0, 1 or 2, got "3"`,
+				},
+				{
+					Path:      "queryConds[0]",
+					Field:     "createAt",
+					QueryType: FUZZY,
+					Msg:       "fuzzy and regexp queries are not supported on date field",
+				},
+				{
+					Path:      "queryConds[1]",
+					Field:     "id",
+					QueryType: IDS,
+					Msg:       "ids query must use _id as key of Pair",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bool prefix",
+			paging: &Paging{
+				QueryConds: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"text": {"考"},
+						},
+						QueryLogic: MUST,
+						QueryType:  BOOLPREFIX,
+					},
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path:      "queryConds[0]",
+					Field:     "text",
+					QueryType: BOOLPREFIX,
+					Msg:       "bool_prefix query is only supported on search_as_you_type field, got text field",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid",
+			paging: &Paging{
+				DateField: "text",
+				QueryConds: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"type": {"education"},
+						},
+						QueryLogic: MUST,
+						QueryType:  TERMS,
+					},
+					{
+						QueryLogic: SHOULD,
+						Children: []QueryCond{
+							{
+								Pair: map[string][]interface{}{
+									"createAt": {"2020-06-01"},
+								},
+								QueryLogic: MUST,
+								QueryType:  RANGE,
+							},
+							{
+								Pair: map[string][]interface{}{
+									"type.keyword": {float64(1)},
+								},
+								QueryLogic: MUST,
+								QueryType:  WILDCARD,
+							},
+							{
+								Pair: map[string][]interface{}{
+									"notexist": {},
+								},
+								QueryLogic: MUSTNOT,
+								QueryType:  EXISTS,
+							},
+						},
+					},
+				},
+				Sortby: []Sort{
+					{
+						Field: "text",
+					},
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path:  "dateField",
+					Field: "text",
+					Msg:   "date range is not supported on text field",
+				},
+				{
+					Path:      "queryConds[0]",
+					Field:     "type",
+					QueryType: TERMS,
+					Msg:       `terms query on text field matches analyzed tokens only, use "type.keyword" instead`,
+				},
+				{
+					Path:      "queryConds[1].children[0]",
+					Field:     "createAt",
+					QueryType: RANGE,
+					Msg:       "range value must be a map with from, to, include_lower and include_upper keys, got string",
+				},
+				{
+					Path:      "queryConds[1].children[1]",
+					Field:     "type.keyword",
+					QueryType: WILDCARD,
+					Msg:       "value must be a non-empty string, got float64",
+				},
+				{
+					Path:      "queryConds[1].children[2]",
+					Field:     "notexist",
+					QueryType: EXISTS,
+					Msg:       "field not found in mapping",
+				},
+				{
+					Path:  "sortby[0]",
+					Field: "text",
+					Msg:   "sorting on text field is not supported, use a keyword field",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "boost and minimum_should_match",
+			paging: &Paging{
+				QueryConds: []QueryCond{
+					{
+						Pair: map[string][]interface{}{
+							"text": {},
+						},
+						QueryLogic: FILTER,
+						QueryType:  EXISTS,
+						Boost:      2,
+					},
+					{
+						Pair: map[string][]interface{}{
+							"type.keyword": {"education"},
+						},
+						QueryLogic:         SHOULD,
+						QueryType:          TERMS,
+						MinimumShouldMatch: "2",
+					},
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path:      "queryConds[0]",
+					QueryType: EXISTS,
+					Msg:       "boost is not supported by this query type",
+				},
+				{
+					Path:      "queryConds[1]",
+					QueryType: TERMS,
+					Msg:       "minimum_should_match is only supported on conditions with children",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relevance",
+			paging: &Paging{
+				Relevance: &Relevance{
+					Functions: []ScoreFunction{
+						{
+							FieldValueFactor: &FieldValueFactor{
+								Field: "text",
+							},
+						},
+						{
+							Decay: &Decay{
+								Field: "createAt",
+								Scale: "10d",
+							},
+						},
+						{},
+					},
+					BoostMode: "first",
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path: "relevance",
+					Msg:  `unknown boost mode "first"`,
+				},
+				{
+					Path:  "relevance.functions[0]",
+					Field: "text",
+					Msg:   "field_value_factor is not supported on text field",
+				},
+				{
+					Path: "relevance.functions[2]",
+					Msg:  "function must have fieldValueFactor, decay, script or weight",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "collapse",
+			paging: &Paging{
+				Limit: -1,
+				Collapse: &Collapse{
+					Field: "text",
+				},
+			},
+			want: ValidationErrors{
+				{
+					Path:  "collapse",
+					Field: "text",
+					Msg:   "collapsing is not supported on text field, use a keyword or numeric field",
+				},
+				{
+					Path:  "collapse",
+					Field: "text",
+					Msg:   "collapsing is not supported when fetching all docs, set limit between 0 and 10000",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := es.Validate(context.Background(), tt.paging)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				assert.Equal(t, tt.want, err)
+			}
+		})
+	}
+}